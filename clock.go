@@ -0,0 +1,51 @@
+package puid
+
+import (
+	"sync"
+	"time"
+)
+
+// The source of time for the timestamps in a puid.
+// The default is the system clock.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// A Clock that only moves when told to, so you can create deterministic
+// ids in tests. It is safe for concurrent use.
+type FakeClock struct {
+	t   time.Time
+	mtx sync.Mutex
+}
+
+// A FakeClock stopped at the given time
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{t: t}
+}
+
+// implements the Clock interface
+func (c *FakeClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.t
+}
+
+// Move the clock to the given time, backwards is allowed
+func (c *FakeClock) Set(t time.Time) {
+	c.mtx.Lock()
+	c.t = t
+	c.mtx.Unlock()
+}
+
+// Move the clock on by d, which may be negative
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	c.t = c.t.Add(d)
+	c.mtx.Unlock()
+}
@@ -0,0 +1,53 @@
+package puid
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_FakeClock(t *testing.T) {
+	start := time.Unix(78364164096/1000, 96*1e6)
+	c := NewFakeClock(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("unexpected time %s", c.Now())
+	}
+	c.Advance(time.Second)
+	if !c.Now().Equal(start.Add(time.Second)) {
+		t.Errorf("unexpected time after Advance %s", c.Now())
+	}
+	c.Set(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("unexpected time after Set %s", c.Now())
+	}
+}
+
+func Test_CustomClock(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 78364164096*1e6)) // "10000000"
+	g := WithClock(c).WithCounter(dumbCounter(0))
+	if id := g.New(); id[1:9] != "10000000" {
+		t.Errorf("unexpected timestamp in id: %s", id)
+	}
+	c.Advance(35 * time.Millisecond)
+	if id := g.New(); id[1:9] != "1000000z" {
+		t.Errorf("unexpected timestamp in id: %s", id)
+	}
+	// and from scratch
+	g = NewGenerator(&Options{Clock: c})
+	if id := g.New(); id[1:9] != "1000000z" {
+		t.Errorf("unexpected timestamp in id: %s", id)
+	}
+	// validation uses the clock too, so this id is now in the future
+	c.Advance(-time.Hour)
+	if err := g.Validate(g.WithClock(NewFakeClock(time.Now())).New()); err == nil {
+		t.Error("expected an id from the future to fail validation")
+	}
+}
+
+func Test_NilClockCausesPanic(t *testing.T) {
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("we should have panic'd on a nil Clock")
+		}
+	}()
+	WithClock(nil)
+}
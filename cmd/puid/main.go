@@ -3,6 +3,8 @@ package main
 import (
 	"flag"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/thechriswalker/puid"
 )
@@ -28,12 +30,27 @@ func example() {
 var (
 	showExample = flag.Bool("example", false, "show example library use and output")
 	count       = flag.Int("count", 1, "how many of each puid to generate")
+	parseIds    = flag.Bool("parse", false, "decode the puids given as arguments instead of generating")
 )
 
+func init() {
+	flag.Func("entity", "register `prefix=name` so -parse can show the entity type (repeatable)", func(s string) error {
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			return fmt.Errorf("expected prefix=name, got %q", s)
+		}
+		return puid.Register(s[:i], s[i+1:], "")
+	})
+}
+
 func main() {
 	flag.Parse()
 	if *showExample {
 		example()
+	} else if *parseIds {
+		for _, s := range flag.Args() {
+			parse(s)
+		}
 	} else {
 		prefixes := flag.Args()
 		if len(prefixes) > 0 {
@@ -51,3 +68,20 @@ func run(g *puid.Generator, c int) {
 		fmt.Println(g.New())
 	}
 }
+
+func parse(s string) {
+	id, err := puid.Parse(s)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(s)
+	fmt.Printf("\tprefix:      %q\n", id.Prefix)
+	if e, ok := puid.Lookup(s); ok {
+		fmt.Printf("\tentity:      %s\n", e.Name)
+	}
+	fmt.Printf("\ttimestamp:   %s\n", id.Timestamp.UTC().Format(time.RFC3339Nano))
+	fmt.Printf("\tcounter:     %d\n", id.Counter)
+	fmt.Printf("\tfingerprint: %s\n", id.Fingerprint)
+	fmt.Printf("\trandom:      %s\n", id.Random)
+}
@@ -1,8 +1,11 @@
 package puid
 
 import (
+	"errors"
+	"io"
 	"strconv"
 	"sync"
+	"sync/atomic"
 )
 
 // This interface is all that's needed to be a Counter
@@ -15,6 +18,45 @@ type Counter interface {
 	Next() int64
 }
 
+// A Counter that can fail, e.g. one that calls out over the network.
+// Give it to the generator with `WithCounterE` or `Options.CounterE`,
+// then NewE/AppendBytesE return the error, New/AppendBytes panic with it.
+type CounterE interface {
+	Next() (int64, error)
+}
+
+// The error returned when a CounterE gives a value out of range
+var ErrCounterRange = errors.New("puid: counter out of range")
+
+// adapts a CounterE so the generator can hold it as a Counter
+type errCounter struct {
+	c CounterE
+}
+
+func (e errCounter) Next() int64 {
+	n, err := e.c.Next()
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// get the next counter value, from a CounterE if we have one
+func (g *Generator) nextCounter() (int64, error) {
+	ec, ok := g.counter.(errCounter)
+	if !ok {
+		return g.counter.Next(), nil
+	}
+	n, err := ec.c.Next()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n >= MAX_COUNTER {
+		return 0, ErrCounterRange
+	}
+	return n, nil
+}
+
 // a simple mutex protected counter
 // I used a seperate interface to test this vs. a channel
 // the channel, was an order of magnitude slower.
@@ -33,8 +75,69 @@ func (c *counterMutex) Next() (n int64) {
 	return
 }
 
-func getDefaultCounter() *counterMutex {
-	return &counterMutex{}
+// A lock free Counter, which is what we use by default.
+// Under contention the mutex was the hottest thing in a profile.
+type AtomicCounter struct {
+	value      atomic.Int64
+	onRollover func()
+}
+
+// Create an AtomicCounter whose first value is start, onRollover (if not nil)
+// is called each time the counter wraps from MAX_COUNTER-1 back to 0.
+// panics if start is < 0 or >= MAX_COUNTER
+func NewAtomicCounter(start int64, onRollover func()) *AtomicCounter {
+	if start < 0 || start >= MAX_COUNTER {
+		panic("NewAtomicCounter called with start out of range")
+	}
+	c := &AtomicCounter{onRollover: onRollover}
+	c.value.Store(start)
+	return c
+}
+
+// implements the Counter interface
+func (c *AtomicCounter) Next() int64 {
+	for {
+		n := c.value.Load()
+		next := n + 1
+		if next == MAX_COUNTER {
+			next = 0
+		}
+		if c.value.CompareAndSwap(n, next) {
+			if next == 0 && c.onRollover != nil {
+				c.onRollover()
+			}
+			return n
+		}
+	}
+}
+
+// The default counter starts at a random offset, so a restarted process
+// with the same pid (and so fingerprint) doesn't repeat the same counter
+// values. zero gives the old behaviour of starting at 0.
+func getDefaultCounter(r Random, zero bool) *AtomicCounter {
+	if zero {
+		return NewAtomicCounter(0, nil)
+	}
+	return NewAtomicCounter(randomCounterStart(r), nil)
+}
+
+// the largest multiple of MAX_COUNTER that fits in 3 bytes, values at or
+// above are thrown away so every start is equally likely
+const counterStartLimit = (1 << 24) / MAX_COUNTER * MAX_COUNTER
+
+// a uniformly random value in [0, MAX_COUNTER), or 0 if r fails us
+func randomCounterStart(r Random) int64 {
+	var b [3]byte
+	for i := 0; i < maxUnbiasedReads; i++ {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0
+		}
+		v := int64(b[0])<<16 | int64(b[1])<<8 | int64(b[2])
+		if v < counterStartLimit {
+			return v % MAX_COUNTER
+		}
+	}
+	return 0
 }
 
 // This is a single Block size of 4 assumed
@@ -82,7 +185,7 @@ func strlen(i int64) (l int) {
 		//now we have to work it out... (bnut we only have to start from 4)
 		l = 4
 		i = i / _4_DIGIT
-		for _1_DIGIT < i {
+		for i >= _1_DIGIT {
 			l++
 			i = i / _1_DIGIT
 		}
@@ -0,0 +1,112 @@
+package puid
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// A RangeAllocator hands out blocks of counter values, usually from
+// somewhere shared by many machines. Reserve returns the start of a range
+// of n values, [start, start+n), that nobody else will be given.
+// The values only ever increase, the BlockCounter wraps them at MAX_COUNTER.
+type RangeAllocator interface {
+	Reserve(ctx context.Context, n int64) (start int64, err error)
+}
+
+// A CounterE that reserves ranges of values from a RangeAllocator and
+// hands them out locally, so it only calls out once per block
+// rather than once per id (a hi/lo counter).
+type BlockCounter struct {
+	alloc   RangeAllocator
+	size    int64
+	timeout time.Duration
+	next    int64 // the next value to hand out
+	limit   int64 // the end of the current range (exclusive)
+	mtx     sync.Mutex
+}
+
+// Create a BlockCounter reserving size values at a time from a.
+// Each Reserve call is given timeout to finish, 0 means no timeout.
+func NewBlockCounter(a RangeAllocator, size int64, timeout time.Duration) *BlockCounter {
+	if a == nil {
+		panic("NewBlockCounter called with nil RangeAllocator")
+	}
+	if size < 1 {
+		panic("NewBlockCounter called with size < 1")
+	}
+	return &BlockCounter{alloc: a, size: size, timeout: timeout}
+}
+
+// implements the CounterE interface
+func (c *BlockCounter) Next() (int64, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.next == c.limit {
+		ctx := context.Background()
+		if c.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+		}
+		start, err := c.alloc.Reserve(ctx, c.size)
+		if err != nil {
+			return 0, err
+		}
+		if start < 0 {
+			return 0, ErrCounterRange
+		}
+		c.next, c.limit = start, start+c.size
+	}
+	n := c.next
+	c.next++
+	return n % MAX_COUNTER, nil
+}
+
+// how long we wait between attempts to lock the allocator file
+const fileAllocatorRetry = time.Millisecond
+
+type fileAllocator struct {
+	path string
+}
+
+// A RangeAllocator keeping its mark in a local file, which many processes
+// can share. The file is locked for each Reserve (see FileCounter for a
+// counter that keeps the file to itself).
+func NewFileAllocator(path string) RangeAllocator {
+	return &fileAllocator{path: path}
+}
+
+// implements the RangeAllocator interface
+func (a *fileAllocator) Reserve(ctx context.Context, n int64) (int64, error) {
+	f, err := os.OpenFile(a.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	for {
+		err = lockFile(f)
+		if !errors.Is(err, ErrFileLocked) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(fileAllocatorRetry):
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer unlockFile(f)
+	start, err := readMark(f)
+	if err != nil {
+		return 0, err
+	}
+	if err := writeMark(f, start+n); err != nil {
+		return 0, err
+	}
+	return start, nil
+}
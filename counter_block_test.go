@@ -0,0 +1,71 @@
+package puid
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// hands out the ranges it is told to
+type listAllocator struct {
+	starts []int64
+	err    error
+	calls  int
+}
+
+func (l *listAllocator) Reserve(ctx context.Context, n int64) (int64, error) {
+	l.calls++
+	if l.err != nil {
+		return 0, l.err
+	}
+	start := l.starts[0]
+	l.starts = l.starts[1:]
+	return start, nil
+}
+
+func Test_BlockCounter(t *testing.T) {
+	a := &listAllocator{starts: []int64{100, 5000, 2*MAX_COUNTER - 1}}
+	c := NewBlockCounter(a, 2, 0)
+	expected := []int64{100, 101, 5000, 5001, MAX_COUNTER - 1, 0}
+	for _, e := range expected {
+		v, err := c.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != e {
+			t.Errorf("expected %d, got %d", e, v)
+		}
+	}
+	if a.calls != 3 {
+		t.Errorf("expected one Reserve per block, got %d", a.calls)
+	}
+	nope := errors.New("nope")
+	a.err = nope
+	if _, err := c.Next(); err != nope {
+		t.Errorf("expected the allocator error, got %v", err)
+	}
+	a.err = nil
+	a.starts = []int64{-1}
+	if _, err := c.Next(); err != ErrCounterRange {
+		t.Errorf("expected ErrCounterRange from a negative start, got %v", err)
+	}
+	if _, err := WithCounterE(NewBlockCounter(&listAllocator{err: nope}, 2, 0)).NewE(); err != nope {
+		t.Error("expected NewE to fail with the allocator")
+	}
+}
+
+func Test_NewBlockCounterPanics(t *testing.T) {
+	for _, f := range []func(){
+		func() { NewBlockCounter(nil, 1, 0) },
+		func() { NewBlockCounter(&listAllocator{}, 0, 0) },
+	} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("we should have panic'd")
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,147 @@
+package puid
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The error when a file used for a counter is locked by someone else
+var ErrFileLocked = errors.New("puid: file is locked by another process")
+
+// A CounterE that keeps its high-water mark in a file, so a process that
+// restarts carries on from where it left off rather than repeating values.
+// It reserves values a block at a time, so it only writes (and fsyncs) the
+// file once per block. After a crash the rest of the reserved block is
+// skipped, values are never handed out twice.
+//
+// The file is locked while the counter is open, so two processes cannot
+// share it by accident. Close it to release the lock.
+type FileCounter struct {
+	f     *os.File
+	block int64
+	next  int64 // the next value to hand out, this only increases
+	limit int64 // we have reserved up to here (exclusive)
+	mtx   sync.Mutex
+}
+
+// Open (or create) the counter file at path, reserving block values at a time.
+// Fails with ErrFileLocked if another FileCounter has the file open.
+func NewFileCounter(path string, block int64) (*FileCounter, error) {
+	if block < 1 {
+		return nil, errors.New("puid: FileCounter block must be at least 1")
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	mark, err := readMark(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	c := &FileCounter{f: f, block: block, next: mark, limit: mark}
+	if err := c.reserve(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// implements the CounterE interface
+func (c *FileCounter) Next() (int64, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.f == nil {
+		return 0, os.ErrClosed
+	}
+	if c.next == c.limit {
+		if err := c.reserve(); err != nil {
+			return 0, err
+		}
+	}
+	n := c.next
+	c.next++
+	return n % MAX_COUNTER, nil
+}
+
+// Write back how far we actually got, so the rest of the block
+// is not wasted, and release the file.
+func (c *FileCounter) Close() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.f == nil {
+		return os.ErrClosed
+	}
+	err := writeMark(c.f, c.next)
+	unlockFile(c.f)
+	if cerr := c.f.Close(); err == nil {
+		err = cerr
+	}
+	c.f = nil
+	return err
+}
+
+// move the mark on a block, before we hand out any of it
+func (c *FileCounter) reserve() error {
+	if err := writeMark(c.f, c.limit+c.block); err != nil {
+		return err
+	}
+	c.limit += c.block
+	return nil
+}
+
+// the mark is kept as a decimal number, an empty file is 0
+func readMark(f *os.File) (int64, error) {
+	b := make([]byte, 32)
+	n, err := f.ReadAt(b, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	s := strings.TrimSpace(string(b[:n]))
+	if s == "" {
+		return 0, nil
+	}
+	mark, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || mark < 0 {
+		return 0, fmt.Errorf("puid: bad counter mark in %s: %q", f.Name(), s)
+	}
+	return mark, nil
+}
+
+// width of the mark in the file, enough for any int64
+const markWidth = 19
+
+// the mark is zero padded to a fixed width, so each write covers all of
+// the last one and a crash part way through cannot leave a mix of the two
+// (a shorter mark followed by the tail of the old one).
+func writeMark(f *os.File, mark int64) error {
+	b := make([]byte, 0, markWidth+1)
+	for l := strlen10(mark); l < markWidth; l++ {
+		b = append(b, '0')
+	}
+	b = strconv.AppendInt(b, mark, 10)
+	b = append(b, '\n')
+	if _, err := f.WriteAt(b, 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// the number of decimal digits in a non-negative number
+func strlen10(n int64) int {
+	l := 1
+	for n >= 10 {
+		n /= 10
+		l++
+	}
+	return l
+}
@@ -0,0 +1,212 @@
+//go:build unix
+
+package puid
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func readCounterFile(t *testing.T, path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := strings.TrimSpace(string(b))
+	if len(s) != markWidth {
+		t.Errorf("mark %q is not %d digits", s, markWidth)
+	}
+	// drop the zero padding
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return strconv.FormatInt(n, 10)
+	}
+	return s
+}
+
+func takeValues(t *testing.T, c CounterE, n int) []int64 {
+	values := make([]int64, n)
+	for i := range values {
+		v, err := c.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		values[i] = v
+	}
+	return values
+}
+
+func Test_FileCounterResumes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	c, err := NewFileCounter(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := takeValues(t, c, 25)
+	for i, v := range values {
+		if v != int64(i) {
+			t.Fatalf("unexpected counter values %v", values)
+		}
+	}
+	// we have reserved 3 blocks
+	if s := readCounterFile(t, path); s != "30" {
+		t.Errorf("unexpected mark in file while open %q", s)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// and gave back what we didn't use
+	if s := readCounterFile(t, path); s != "25" {
+		t.Errorf("unexpected mark in file after close %q", s)
+	}
+	if _, err := c.Next(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected an error from a closed counter, got %v", err)
+	}
+
+	c, err = NewFileCounter(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if v := takeValues(t, c, 1); v[0] != 25 {
+		t.Errorf("expected to resume at 25, got %d", v[0])
+	}
+}
+
+func Test_FileCounterCrashSkipsReserved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	c, err := NewFileCounter(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	takeValues(t, c, 3)
+	// like a crash, the file is closed without writing
+	c.f.Close()
+
+	c, err = NewFileCounter(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if v := takeValues(t, c, 1); v[0] != 10 {
+		t.Errorf("expected to skip the reserved block and start at 10, got %d", v[0])
+	}
+}
+
+func Test_FileCounterMarkShrinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	// a mark written before they were padded
+	os.WriteFile(path, []byte("1000\n"), 0o644)
+	c, err := NewFileCounter(path, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	takeValues(t, c, 1)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// giving back most of the block writes a smaller number, which
+	// must still cover every byte of the bigger one
+	b, _ := os.ReadFile(path)
+	if len(b) != markWidth+1 {
+		t.Errorf("expected a fixed width mark, got %q", b)
+	}
+	if s := readCounterFile(t, path); s != "1001" {
+		t.Errorf("unexpected mark in file after close %q", s)
+	}
+}
+
+func Test_FileCounterIsLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	c, err := NewFileCounter(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileCounter(path, 10); !errors.Is(err, ErrFileLocked) {
+		t.Errorf("expected ErrFileLocked opening the file twice, got %v", err)
+	}
+	c.Close()
+	c, err = NewFileCounter(path, 10)
+	if err != nil {
+		t.Errorf("expected to open the file after close, got %v", err)
+	} else {
+		c.Close()
+	}
+}
+
+func Test_FileCounterWrapsAndGenerates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	os.WriteFile(path, []byte("3359230\n"), 0o644) // 2*MAX_COUNTER - 2
+	c, err := NewFileCounter(path, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	values := takeValues(t, c, 3)
+	if values[0] != MAX_COUNTER-2 || values[1] != MAX_COUNTER-1 || values[2] != 0 {
+		t.Errorf("expected the values to wrap at MAX_COUNTER, got %v", values)
+	}
+	if id, err := WithCounterE(c).NewE(); err != nil || id[9:9+BLOCK] != "0001" {
+		t.Errorf("unexpected id from a FileCounter %q %v", id, err)
+	}
+
+	os.WriteFile(path+"2", []byte("nope"), 0o644)
+	if _, err := NewFileCounter(path+"2", 10); err == nil {
+		t.Error("expected an error from a bad mark")
+	}
+}
+
+func Test_FileAllocatorShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ranges")
+	seen := map[int64]bool{}
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// each with its own allocator, like separate processes
+			c := NewBlockCounter(NewFileAllocator(path), 10, time.Second)
+			for i := 0; i < 200; i++ {
+				v, err := c.Next()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mtx.Lock()
+				if seen[v] {
+					t.Errorf("value %d handed out twice", v)
+				}
+				seen[v] = true
+				mtx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if s := readCounterFile(t, path); s != "800" {
+		t.Errorf("unexpected mark in file %q", s)
+	}
+}
+
+func Test_FileAllocatorWaitsForLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ranges")
+	held, err := NewFileCounter(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := NewFileAllocator(path).Reserve(ctx, 10); err != context.DeadlineExceeded {
+		t.Errorf("expected to time out waiting for the lock, got %v", err)
+	}
+	held.Close()
+	if start, err := NewFileAllocator(path).Reserve(context.Background(), 10); err != nil || start != 0 {
+		t.Errorf("unexpected range after the lock was released %d %v", start, err)
+	}
+}
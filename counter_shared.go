@@ -0,0 +1,74 @@
+package puid
+
+import (
+	"os"
+	"sync/atomic"
+	"unsafe"
+)
+
+// the counter is a single int64 at the start of the file
+const sharedCounterSize = 8
+
+// A Counter shared by every process on the host that opens the same file.
+// The file is memory mapped and the value incremented atomically, so a
+// pre-fork server's workers share one counter sequence and ids from the
+// host are unique however their pids map to fingerprints.
+// The file's contents are only meaningful on the host that wrote them.
+type SharedCounter struct {
+	f     *os.File
+	mem   []byte
+	value *atomic.Int64 // points into mem
+}
+
+// Open (or create) the shared counter file at path
+func NewSharedCounter(path string) (*SharedCounter, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err == nil && info.Size() < sharedCounterSize {
+		// growing the file doesn't touch what is there, so it
+		// doesn't matter if another process does this too
+		err = f.Truncate(sharedCounterSize)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	mem, err := mmapFile(f, sharedCounterSize)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	// mappings are page aligned, so this is fine for atomic access
+	return &SharedCounter{f: f, mem: mem, value: (*atomic.Int64)(unsafe.Pointer(&mem[0]))}, nil
+}
+
+// implements the Counter interface
+func (c *SharedCounter) Next() int64 {
+	for {
+		old := c.value.Load()
+		n := old
+		if n < 0 || n >= MAX_COUNTER {
+			// someone wrote rubbish in the file, start again
+			n = 0
+		}
+		next := n + 1
+		if next == MAX_COUNTER {
+			next = 0
+		}
+		if c.value.CompareAndSwap(old, next) {
+			return n
+		}
+	}
+}
+
+// Unmap and close the file, the counter must not be used afterwards
+func (c *SharedCounter) Close() error {
+	err := munmap(c.mem)
+	if cerr := c.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
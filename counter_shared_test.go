@@ -0,0 +1,79 @@
+//go:build unix
+
+package puid
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func Test_SharedCounterAcrossMappings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared")
+	// each mapping is what a separate process would have
+	workers, each := 4, 50000
+	counters := make([]*SharedCounter, workers)
+	for i := range counters {
+		c, err := NewSharedCounter(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		counters[i] = c
+	}
+	seen := make([][]int64, workers)
+	var wg sync.WaitGroup
+	for w, c := range counters {
+		wg.Add(1)
+		go func(w int, c *SharedCounter) {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				seen[w] = append(seen[w], c.Next())
+			}
+		}(w, c)
+	}
+	wg.Wait()
+	values := map[int64]bool{}
+	for _, list := range seen {
+		for _, v := range list {
+			if values[v] {
+				t.Fatalf("counter value %d handed out twice", v)
+			}
+			values[v] = true
+		}
+	}
+	if len(values) != workers*each {
+		t.Errorf("expected %d distinct values, got %d", workers*each, len(values))
+	}
+	// and a new one carries on from there
+	c, err := NewSharedCounter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if v := c.Next(); v != int64(workers*each) {
+		t.Errorf("expected to carry on from %d, got %d", workers*each, v)
+	}
+}
+
+func Test_SharedCounterRollover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared")
+	b := make([]byte, 8)
+	binary.NativeEndian.PutUint64(b, MAX_COUNTER-1)
+	os.WriteFile(path, b, 0o644)
+	c, err := NewSharedCounter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b := c.Next(), c.Next(); a != MAX_COUNTER-1 || b != 0 {
+		t.Errorf("expected the counter to roll over, got %d then %d", a, b)
+	}
+	// rubbish in the file starts again from 0
+	c.value.Store(-5)
+	if v := c.Next(); v != 0 {
+		t.Errorf("expected 0 after a bad value, got %d", v)
+	}
+	c.Close()
+}
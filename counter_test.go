@@ -2,7 +2,10 @@ package puid
 
 import (
 	"bytes"
+	"errors"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -22,6 +25,92 @@ func Test_CounterRollover(t *testing.T) {
 	}
 }
 
+func Test_AtomicCounterRollover(t *testing.T) {
+	rollovers := 0
+	ctr := NewAtomicCounter(MAX_COUNTER-2, func() { rollovers++ })
+	for _, e := range []int64{MAX_COUNTER - 2, MAX_COUNTER - 1, 0, 1} {
+		if c := ctr.Next(); c != e {
+			t.Fatalf("unexpected counter value %d, expected %d", c, e)
+		}
+	}
+	if rollovers != 1 {
+		t.Errorf("expected 1 rollover callback, got %d", rollovers)
+	}
+}
+
+func Test_AtomicCounterConcurrent(t *testing.T) {
+	var rollovers atomic.Int64
+	ctr := NewAtomicCounter(MAX_COUNTER/2, func() { rollovers.Add(1) })
+	workers, each := 8, MAX_COUNTER/8
+	seen := make([][]int64, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				seen[w] = append(seen[w], ctr.Next())
+			}
+		}(w)
+	}
+	wg.Wait()
+	// exactly one full loop, so every value exactly once, and one rollover
+	values := make([]bool, MAX_COUNTER)
+	for _, list := range seen {
+		for _, c := range list {
+			if c < 0 || c >= MAX_COUNTER {
+				t.Fatalf("counter value %d out of range", c)
+			}
+			if values[c] {
+				t.Fatalf("counter value %d handed out twice", c)
+			}
+			values[c] = true
+		}
+	}
+	if n := rollovers.Load(); n != 1 {
+		t.Errorf("expected 1 rollover callback, got %d", n)
+	}
+}
+
+func Test_AtomicCounterStartOutOfRangeCausesPanic(t *testing.T) {
+	for _, start := range []int64{-1, MAX_COUNTER} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Errorf("we should have panic'd on a start of %d", start)
+				}
+			}()
+			NewAtomicCounter(start, nil)
+		}()
+	}
+	if c := NewAtomicCounter(1337, nil); c.Next() != 1337 {
+		t.Error("unexpected first value from counter")
+	}
+}
+
+func Test_DefaultCounterRandomStart(t *testing.T) {
+	// 0x010101 is 65793 => "1erl" in base36
+	g := NewGenerator(&Options{Random: ones})
+	if id := g.New(); id[9:9+BLOCK] != "1erl" {
+		t.Errorf("expected the counter to start at a random offset, got %s", id)
+	}
+	// unless we ask for zero
+	g = NewGenerator(&Options{Random: ones, ZeroCounter: true})
+	if id := g.New(); id[9:9+BLOCK] != "0000" {
+		t.Errorf("expected the counter to start at 0, got %s", id)
+	}
+	// values over the limit are thrown away
+	if n := randomCounterStart(badRandom(0xff)); n != 0 {
+		t.Errorf("expected 0 from a random with nothing usable, got %d", n)
+	}
+	// and a couple of fresh generators should (almost certainly) differ
+	a, b := NewGenerator(nil).counter.Next(), NewGenerator(nil).counter.Next()
+	c := NewGenerator(nil).counter.Next()
+	if a == b && b == c {
+		t.Errorf("three default counters started at the same value %d", a)
+	}
+}
+
 type dumbCounter int64
 
 func (d dumbCounter) Next() int64 {
@@ -65,7 +154,11 @@ func Test_Strlen(t *testing.T) {
 		{36 * 36 * 36, 4},
 		{36*36*36 + 100, 4},
 		{36*36*36 - 1, 3},
-		{36 * 36 * 36 * 36 * 36 * 36, 6},
+		{36*36*36*36*36 - 1, 5},
+		{36 * 36 * 36 * 36 * 36, 6},
+		{36 * 36 * 36 * 36 * 36 * 36, 7},
+		{78364164096, 8}, // "10000000"
+		{78364164096 * 36, 9},
 	}
 
 	for _, test := range tests {
@@ -100,3 +193,42 @@ func Test_AppendPaddedInt(t *testing.T) {
 		}
 	}
 }
+
+type failingCounter struct {
+	n   int64
+	err error
+}
+
+func (f failingCounter) Next() (int64, error) {
+	return f.n, f.err
+}
+
+func Test_CounterEErrors(t *testing.T) {
+	boom := errors.New("boom")
+	g := WithCounterE(failingCounter{err: boom})
+	if id, err := g.NewE(); err != boom || id != "" {
+		t.Errorf("expected the counter error from NewE, got %q %v", id, err)
+	}
+	buff := []byte("keep")
+	if b, err := g.AppendBytesE(buff); err != boom || string(b) != "keep" {
+		t.Errorf("expected the counter error and untouched buffer from AppendBytesE, got %q %v", b, err)
+	}
+	func() {
+		defer func() {
+			if err := recover(); err != boom {
+				t.Errorf("expected New to panic with the counter error, got %v", err)
+			}
+		}()
+		g.New()
+	}()
+
+	// out of range values are an error too
+	g = NewGenerator(&Options{CounterE: failingCounter{n: MAX_COUNTER}})
+	if _, err := g.NewE(); err != ErrCounterRange {
+		t.Errorf("expected ErrCounterRange, got %v", err)
+	}
+	g = g.WithCounterE(failingCounter{n: 1337})
+	if id, err := g.NewE(); err != nil || id[9:9+BLOCK] != "0115" {
+		t.Errorf("unexpected result from a working CounterE: %q %v", id, err)
+	}
+}
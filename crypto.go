@@ -0,0 +1,47 @@
+package puid
+
+import (
+	crand "crypto/rand"
+	"io"
+	"sync"
+)
+
+// how much we pull from crypto/rand at a time, each id uses about 10 bytes
+const cryptoBufferSize = 4096
+
+// crypto/rand is slow per call, but not per byte, so we read a big chunk
+// and hand it out a few bytes at a time
+type cryptoRandom struct {
+	buf [cryptoBufferSize]byte
+	pos int // how much of buf has been handed out
+	mtx sync.Mutex
+}
+
+// A source of unguessable randomness for our Generator, for ids that
+// must not be predictable (e.g. exposed in URLs). It reads crypto/rand
+// in large chunks so it is not much slower than NewMathRandom.
+func NewCryptoRandom() Random {
+	return &cryptoRandom{pos: cryptoBufferSize}
+}
+
+// implements the Random interface
+func (c *cryptoRandom) Read(b []byte) (n int, err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for n < len(b) {
+		if c.pos == len(c.buf) {
+			if _, err = io.ReadFull(crand.Reader, c.buf[:]); err != nil {
+				return
+			}
+			c.pos = 0
+		}
+		m := copy(b[n:], c.buf[c.pos:])
+		// don't keep a copy of what we handed out
+		for i := c.pos; i < c.pos+m; i++ {
+			c.buf[i] = 0
+		}
+		c.pos += m
+		n += m
+	}
+	return
+}
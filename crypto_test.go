@@ -0,0 +1,33 @@
+package puid
+
+import (
+	"testing"
+)
+
+func Test_CryptoRandom(t *testing.T) {
+	r := NewCryptoRandom()
+	// more than the buffer, so we refill part way through
+	b := make([]byte, cryptoBufferSize+100)
+	n, err := r.Read(b)
+	if err != nil || n != len(b) {
+		t.Fatalf("unexpected read result %d %v", n, err)
+	}
+	b, err = appendUnbiasedBase36(nil, r, 1000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x2 := chiSquare(b); x2 > chiSquareCritical {
+		t.Errorf("crypto random distribution is not flat, chi-square = %.2f", x2)
+	}
+	g := WithRandom(r)
+	if err := g.Validate(g.New()); err != nil {
+		t.Error(err)
+	}
+}
+
+func Benchmark_PuidWithCryptoRandom(b *testing.B) {
+	g := WithRandom(NewCryptoRandom())
+	for i := 0; i < b.N; i++ {
+		g.New()
+	}
+}
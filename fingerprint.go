@@ -1,6 +1,8 @@
 package puid
 
 import (
+	"encoding/binary"
+	"hash/fnv"
 	"os"
 	"strconv"
 )
@@ -29,6 +31,8 @@ func getDefaultFingerprint() []byte {
 // from the hostname and pid, but you can use whatever you like.
 // This function ensures the created fingerprint is suitable for
 // use in the puid. That is, it consists only of base36 characters.
+// It only has 1296 values for each num and similar strings collide
+// easily, see CreateFingerprintV2 for a better spread.
 func CreateFingerprint(str string, num int64) []byte {
 	// we need BLOCK size bytes
 	// most implementations use hostname and pid
@@ -56,3 +60,24 @@ func CreateFingerprint(str string, num int64) []byte {
 	}
 	return strconv.AppendInt(fp, n, BASE)
 }
+
+// Like CreateFingerprint, but hashes all of str and num together with
+// FNV-1a, so every character counts and similar hostnames ("web-1",
+// "web-2") or anagrams get unrelated fingerprints. The hash is reduced to
+// the MAX_COUNTER possible fingerprints without bias.
+func CreateFingerprintV2(str string, num int64) []byte {
+	h := fnv.New64a()
+	h.Write([]byte(str))
+	// a separator, so ("a1", 2) and ("a", 12) are different identities
+	h.Write([]byte{0})
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(num)))
+	v := h.Sum64()
+	// values at the top don't cover every fingerprint equally often,
+	// so we hash again until we get one that isn't. This almost never happens.
+	limit := ^uint64(0) - ^uint64(0)%MAX_COUNTER
+	for v >= limit {
+		h.Write(binary.BigEndian.AppendUint64(nil, v))
+		v = h.Sum64()
+	}
+	return appendPaddedInt(make([]byte, 0, BLOCK), int64(v%MAX_COUNTER), BLOCK)
+}
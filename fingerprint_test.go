@@ -1,6 +1,7 @@
 package puid
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -99,3 +100,63 @@ func Test_MissingHostnameStillWorks(t *testing.T) {
 		t.Errorf("unexpected default fingerprint `%s`, expected `%s`", fp1, fp2)
 	}
 }
+
+func Test_FingerprintV2(t *testing.T) {
+	fp := CreateFingerprintV2("web-1", 1)
+	if len(fp) != BLOCK || !isAllBase36(fp) {
+		t.Errorf("unexpected fingerprint `%s`", fp)
+	}
+	if string(CreateFingerprintV2("web-1", 1)) != string(fp) {
+		t.Error("fingerprint should be the same for the same identity")
+	}
+	// these all collide with the old algorithm
+	pairs := [][2]string{{"web-1", "web-2"}, {"web-12", "web-21"}, {"db", "bd"}}
+	for _, p := range pairs {
+		if string(CreateFingerprintV2(p[0], 1)) == string(CreateFingerprintV2(p[1], 1)) {
+			t.Errorf("`%s` and `%s` have the same fingerprint", p[0], p[1])
+		}
+	}
+	if string(CreateFingerprintV2("a1", 2)) == string(CreateFingerprintV2("a", 12)) {
+		t.Error("hostname and pid should not run into each other")
+	}
+}
+
+// hostnames like we see in a fleet, all with the same pid as they are
+// containers, so only the hostname tells them apart
+func fleetHostnames() []string {
+	var hosts []string
+	for i := 1; i <= 200; i++ {
+		hosts = append(hosts, fmt.Sprintf("web-%d", i))
+		hosts = append(hosts, fmt.Sprintf("ip-10-0-%d-%d.ec2.internal", i/16, i%16*7))
+		// kubernetes pod names: deployment, replicaset hash, pod suffix
+		hosts = append(hosts, fmt.Sprintf("api-7d9f8b6c5d-%s", appendPaddedInt(nil, int64(i)*7919, 5)))
+	}
+	return hosts
+}
+
+func countCollisions(hosts []string, fn func(string, int64) []byte) int {
+	seen := map[string]bool{}
+	collisions := 0
+	for _, h := range hosts {
+		fp := string(fn(h, 1))
+		if seen[fp] {
+			collisions++
+		}
+		seen[fp] = true
+	}
+	return collisions
+}
+
+func Test_FingerprintCollisionRate(t *testing.T) {
+	hosts := fleetHostnames()
+	v1 := countCollisions(hosts, CreateFingerprint)
+	v2 := countCollisions(hosts, CreateFingerprintV2)
+	t.Logf("collisions over %d hosts: CreateFingerprint %d, CreateFingerprintV2 %d", len(hosts), v1, v2)
+	// with 600 ids in 36^4 fingerprints we expect 0.1 collisions by chance
+	if v2 > 2 {
+		t.Errorf("too many collisions with CreateFingerprintV2: %d", v2)
+	}
+	if v2 >= v1 {
+		t.Errorf("CreateFingerprintV2 should collide less than CreateFingerprint (%d vs %d)", v2, v1)
+	}
+}
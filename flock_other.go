@@ -0,0 +1,18 @@
+//go:build !unix
+
+package puid
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoFileLocks = errors.New("puid: file locking is not supported on this platform")
+
+func lockFile(f *os.File) error {
+	return errNoFileLocks
+}
+
+func unlockFile(f *os.File) error {
+	return errNoFileLocks
+}
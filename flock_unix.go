@@ -0,0 +1,22 @@
+//go:build unix
+
+package puid
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// take an exclusive lock on the file, without waiting if someone else has it
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrFileLocked
+	}
+	return err
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
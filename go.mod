@@ -1,6 +1,6 @@
 module github.com/thechriswalker/puid
 
-go 1.17
+go 1.22
 
 require (
 	github.com/lucsky/cuid v1.2.1
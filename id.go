@@ -0,0 +1,124 @@
+package puid
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Generate a puid as an ID value.
+// panics if the clock is so far off the id does not parse (before 1972)
+func (g *Generator) NewID() ID {
+	id, err := g.Parse(g.New())
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// Returns a puid from the default generator as an ID value
+func NewID() ID {
+	return defaultGenerator.NewID()
+}
+
+// Whether this is the empty ID, i.e. nothing was parsed into it
+func (id ID) IsZero() bool {
+	return id.Timestamp.IsZero()
+}
+
+// Put the segments back together into the puid string.
+// The zero ID is the empty string.
+func (id ID) String() string {
+	if id.IsZero() {
+		return ""
+	}
+	b := make([]byte, 0, len(id.Prefix)+bodyLength)
+	b = append(b, id.Prefix...)
+	b = strconv.AppendInt(b, id.Timestamp.UnixNano()/int64(time.Millisecond), BASE)
+	b = appendPaddedInt(b, id.Counter, BLOCK)
+	b = append(b, id.Fingerprint...)
+	b = append(b, id.Random...)
+	return string(b)
+}
+
+// All the Unmarshal/Scan methods validate the id the same way as
+// `puid.Validate`. If the ID is zero but has its Prefix set, the prefix
+// acts as a constraint and ids with any other prefix are rejected, so
+//
+//	u := puid.ID{Prefix: "u"}
+//	err := json.Unmarshal(data, &u)
+//
+// will fail for an "o..." id. An ID that already holds an id is not a
+// constraint, so one value can be reused (e.g. to scan rows) for ids with
+// any prefix.
+func (id *ID) set(s string) error {
+	v, err := parseValid(s)
+	if err != nil {
+		return err
+	}
+	if id.IsZero() && id.Prefix != "" && v.Prefix != id.Prefix {
+		return &ParseError{Input: s, Err: ErrPrefix}
+	}
+	*id = v
+	return nil
+}
+
+// implements encoding.TextMarshaler
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// implements encoding.TextUnmarshaler
+func (id *ID) UnmarshalText(b []byte) error {
+	return id.set(string(b))
+}
+
+// implements json.Marshaler, the zero ID is `null`
+func (id ID) MarshalJSON() ([]byte, error) {
+	if id.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(id.String())
+}
+
+// implements json.Unmarshaler, `null` leaves the ID untouched
+func (id *ID) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return id.set(s)
+}
+
+// implements driver.Valuer, the zero ID is NULL
+func (id ID) Value() (driver.Value, error) {
+	if id.IsZero() {
+		return nil, nil
+	}
+	return id.String(), nil
+}
+
+// implements sql.Scanner, NULL gives the zero ID (keeping any Prefix constraint,
+// but not the prefix of an id it held)
+func (id *ID) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		if id.IsZero() {
+			*id = ID{Prefix: id.Prefix}
+		} else {
+			*id = ID{}
+		}
+		return nil
+	case string:
+		return id.set(v)
+	case []byte:
+		return id.set(string(v))
+	default:
+		return fmt.Errorf("puid: cannot scan %T into ID", src)
+	}
+}
@@ -0,0 +1,140 @@
+package puid
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// make sure we implement everything we say we do
+var (
+	_ sql.Scanner              = (*ID)(nil)
+	_ driver.Valuer            = ID{}
+	_ encoding.TextMarshaler   = ID{}
+	_ encoding.TextUnmarshaler = (*ID)(nil)
+	_ json.Marshaler           = ID{}
+	_ json.Unmarshaler         = (*ID)(nil)
+	_ fmt.Stringer             = ID{}
+)
+
+func Test_IDString(t *testing.T) {
+	for _, s := range []string{"x100000001111ffffrrrrrrrr", New(), WithPrefix("foo:").New()} {
+		id, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id.String() != s {
+			t.Errorf("ID did not round trip, expected `%s` got `%s`", s, id)
+		}
+	}
+	if (ID{}).String() != "" {
+		t.Error("zero ID should be the empty string")
+	}
+	id := WithPrefix("u").NewID()
+	if id.Prefix != "u" || id.IsZero() {
+		t.Errorf("unexpected NewID result %+v", id)
+	}
+}
+
+type user struct {
+	ID    ID  `json:"id"`
+	Order *ID `json:"order"`
+}
+
+func Test_IDJSON(t *testing.T) {
+	in := user{ID: WithPrefix("u").NewID()}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"id":"`+in.ID.String()+`","order":null}` {
+		t.Errorf("unexpected json: %s", b)
+	}
+	var out user
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.ID != in.ID || out.Order != nil {
+		t.Errorf("json did not round trip: %+v", out)
+	}
+
+	// prefix constraints
+	out = user{ID: ID{Prefix: "u"}}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Errorf("matching prefix should unmarshal: %s", err)
+	}
+	out = user{ID: ID{Prefix: "o"}}
+	if err := json.Unmarshal(b, &out); !errors.Is(err, ErrPrefix) {
+		t.Errorf("mismatched prefix should not unmarshal: %v", err)
+	}
+	out = user{ID: ID{Prefix: "us"}}
+	if err := json.Unmarshal([]byte(`{"id":"`+WithPrefix("s").New()+`"}`), &out); !errors.Is(err, ErrPrefix) {
+		t.Errorf("overlapping prefix should not unmarshal: %v", err)
+	}
+
+	// and validation
+	for _, bad := range []string{`{"id":""}`, `{"id":"nope"}`, `{"id":"u0zzzzzzz1111ffffrrrrrrrr"}`, `{"id":1}`} {
+		if err := json.Unmarshal([]byte(bad), &out); err == nil {
+			t.Errorf("expected error unmarshalling %s", bad)
+		}
+	}
+}
+
+func Test_IDText(t *testing.T) {
+	id := NewID()
+	b, _ := id.MarshalText()
+	var out ID
+	if err := out.UnmarshalText(b); err != nil || out != id {
+		t.Errorf("text did not round trip: %+v %v", out, err)
+	}
+}
+
+func Test_IDSQL(t *testing.T) {
+	id := NewID()
+	v, err := id.Value()
+	if err != nil || v != id.String() {
+		t.Errorf("unexpected driver.Value %v %v", v, err)
+	}
+	if v, _ := (ID{}).Value(); v != nil {
+		t.Errorf("zero ID should be NULL, got %v", v)
+	}
+	var out ID
+	for _, src := range []interface{}{id.String(), []byte(id.String())} {
+		out = ID{}
+		if err := out.Scan(src); err != nil || out != id {
+			t.Errorf("scan of %T failed: %+v %v", src, out, err)
+		}
+	}
+	out = ID{Prefix: "o"}
+	if err := out.Scan(id.String()); !errors.Is(err, ErrPrefix) {
+		t.Errorf("scan should respect the prefix constraint: %v", err)
+	}
+	if err := out.Scan(nil); err != nil || !out.IsZero() || out.Prefix != "o" {
+		t.Errorf("scanning NULL should give the zero ID and keep the constraint: %+v %v", out, err)
+	}
+	if err := out.Scan(42); err == nil {
+		t.Error("scanning an int should fail")
+	}
+}
+
+func Test_IDScanReuse(t *testing.T) {
+	u, o := WithPrefix("u").NewID(), WithPrefix("o").NewID()
+	// like `for rows.Next() { rows.Scan(&id) }` over a column of mixed ids
+	var id ID
+	for _, src := range []interface{}{u.String(), o.String(), nil, u.String()} {
+		if err := id.Scan(src); err != nil {
+			t.Fatalf("scanning %v into a reused ID: %v", src, err)
+		}
+	}
+	if id != u {
+		t.Errorf("expected the last id scanned, got %+v", id)
+	}
+	// the same goes for JSON
+	if err := json.Unmarshal([]byte(`"`+o.String()+`"`), &id); err != nil || id != o {
+		t.Errorf("unmarshalling into a reused ID: %+v %v", id, err)
+	}
+}
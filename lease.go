@@ -0,0 +1,130 @@
+package puid
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// The error when a fingerprint lease has been lost, NewE and AppendBytesE
+// on a generator using the lease return it (New panics)
+var ErrLeaseLost = errors.New("puid: fingerprint lease lost")
+
+// A fingerprint held by this instance alone, until it expires
+type FingerprintLease interface {
+	// the leased fingerprint, BLOCK base36 characters
+	Fingerprint() []byte
+	// when the lease runs out, unless it is renewed
+	Expires() time.Time
+	// extend the lease, fails with ErrLeaseLost if it is gone
+	Renew(ctx context.Context) error
+	// give the fingerprint back
+	Release(ctx context.Context) error
+}
+
+// Something that hands out fingerprints no other live instance holds,
+// e.g. a directory of lock files or a database table
+type FingerprintLeaser interface {
+	Acquire(ctx context.Context) (FingerprintLease, error)
+}
+
+// how long we give Release when the lease is given up
+const releaseTimeout = 5 * time.Second
+
+// Renew the lease every interval until ctx is done, then release it.
+// If the lease is lost, because someone else took it or we could not renew
+// it before it expired, lost is called and we stop. This blocks, so you
+// probably want to call it in a goroutine.
+func KeepLease(ctx context.Context, l FingerprintLease, interval time.Duration, lost func(error)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			rctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+			l.Release(rctx)
+			cancel()
+			return
+		case <-t.C:
+		}
+		rctx, cancel := context.WithTimeout(ctx, interval)
+		err := l.Renew(rctx)
+		cancel()
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+		if errors.Is(err, ErrLeaseLost) || !time.Now().Before(l.Expires()) {
+			if lost != nil {
+				lost(err)
+			}
+			return
+		}
+		// otherwise we try again next time, we still have it for now
+	}
+}
+
+// the lease a generator's fingerprint came from, shared by its clones
+type leaseState struct {
+	lease FingerprintLease
+	once  sync.Once
+	done  chan struct{}
+}
+
+func (s *leaseState) lose() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// whether we may still issue ids with the fingerprint
+func (s *leaseState) check() error {
+	select {
+	case <-s.done:
+		return ErrLeaseLost
+	default:
+	}
+	// in case renewing has stalled
+	if !time.Now().Before(s.lease.Expires()) {
+		s.lose()
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Return a new generator like this one, but with a fingerprint leased from
+// the leaser. The lease is renewed in the background until ctx is done,
+// when it is released. Once the lease is lost or released the generator
+// stops issuing ids: NewE and AppendBytesE return ErrLeaseLost and
+// New panics.
+func (g *Generator) WithLeasedFingerprint(ctx context.Context, leaser FingerprintLeaser) (*Generator, error) {
+	l, err := leaser.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := &leaseState{lease: l, done: make(chan struct{})}
+	// renew well before it runs out, so one failure does not lose it
+	interval := time.Until(l.Expires()) / 3
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+	go func() {
+		KeepLease(ctx, l, interval, func(error) { s.lose() })
+		s.lose()
+	}()
+	n := g.WithFingerprintBytes(l.Fingerprint())
+	n.lease = s
+	return n, nil
+}
+
+// Returns the default generator but with a leased fingerprint
+func WithLeasedFingerprint(ctx context.Context, leaser FingerprintLeaser) (*Generator, error) {
+	return defaultGenerator.WithLeasedFingerprint(ctx, leaser)
+}
+
+// A channel that is closed when the generator's fingerprint lease is lost
+// or released, nil if the fingerprint is not leased
+func (g *Generator) LeaseLost() <-chan struct{} {
+	if g.lease == nil {
+		return nil
+	}
+	return g.lease.done
+}
@@ -0,0 +1,112 @@
+package puid
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// how often a file lease checks its lock file is still there
+const fileLeaseInterval = time.Second
+
+// Leases fingerprints between processes on one machine (or sharing a
+// filesystem with working locks), with a lock file for each fingerprint
+// in a directory. The lock goes when the process does, so it never
+// needs to expire.
+type FileFingerprintLeaser struct {
+	dir string
+}
+
+// Create a FileFingerprintLeaser keeping its lock files in dir,
+// which is created if needed
+func NewFileFingerprintLeaser(dir string) *FileFingerprintLeaser {
+	return &FileFingerprintLeaser{dir: dir}
+}
+
+// A fingerprint leased by holding a lock file
+type fileLease struct {
+	mtx         sync.Mutex
+	f           *os.File
+	fingerprint string
+	checked     atomic.Int64 // unix milliseconds
+}
+
+// Find a fingerprint nobody has the lock file for and lock it.
+// We start from a random one, so processes don't all fight over the same.
+func (l *FileFingerprintLeaser) Acquire(ctx context.Context) (FingerprintLease, error) {
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return nil, err
+	}
+	n := randomCounterStart(getDefaultRandom())
+	for i := 0; i < maxLeaseAttempts; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		fp := string(appendPaddedInt(nil, (n+int64(i))%MAX_COUNTER, BLOCK))
+		f, err := os.OpenFile(filepath.Join(l.dir, fp+".lock"), os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockFile(f); err != nil {
+			f.Close()
+			if errors.Is(err, ErrFileLocked) {
+				continue
+			}
+			return nil, err
+		}
+		// we never remove the lock files, someone may have one open
+		// waiting to lock it, and would then hold a lock on a file
+		// nobody else can see
+		lease := &fileLease{f: f, fingerprint: fp}
+		lease.checked.Store(time.Now().UnixMilli())
+		return lease, nil
+	}
+	return nil, ErrNoFingerprint
+}
+
+func (l *fileLease) Fingerprint() []byte {
+	return []byte(l.fingerprint)
+}
+
+// we hold the lock until we let it go, but check every so often that the
+// file has not been removed from under us
+func (l *fileLease) Expires() time.Time {
+	return time.UnixMilli(l.checked.Load()).Add(3 * fileLeaseInterval)
+}
+
+func (l *fileLease) Renew(ctx context.Context) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.f == nil {
+		return ErrLeaseLost
+	}
+	held, err := l.f.Stat()
+	if err != nil {
+		return err
+	}
+	now, err := os.Stat(l.f.Name())
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !os.SameFile(held, now)) {
+		return ErrLeaseLost
+	}
+	if err != nil {
+		return err
+	}
+	l.checked.Store(time.Now().UnixMilli())
+	return nil
+}
+
+func (l *fileLease) Release(ctx context.Context) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	unlockFile(l.f)
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
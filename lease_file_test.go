@@ -0,0 +1,89 @@
+//go:build unix
+
+package puid
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_FileFingerprintLeaser(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "leases")
+	l := NewFileFingerprintLeaser(dir)
+	seen := map[string]bool{}
+	var leases []FingerprintLease
+	for i := 0; i < 10; i++ {
+		lease, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		fp := string(lease.Fingerprint())
+		if len(fp) != BLOCK || !isAllBase36(lease.Fingerprint()) {
+			t.Errorf("unexpected fingerprint `%s`", fp)
+		}
+		if seen[fp] {
+			t.Errorf("fingerprint `%s` leased twice", fp)
+		}
+		seen[fp] = true
+		leases = append(leases, lease)
+	}
+	for _, lease := range leases {
+		if err := lease.Renew(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := lease.Release(context.Background()); err != nil {
+			t.Error(err)
+		}
+		// so it can be taken again
+		f, err := os.OpenFile(filepath.Join(dir, string(lease.Fingerprint())+".lock"), os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := lockFile(f); err != nil {
+			t.Errorf("released lock file is still locked: %v", err)
+		}
+		f.Close()
+	}
+}
+
+func Test_FileFingerprintLeaseLost(t *testing.T) {
+	dir := t.TempDir()
+	lease, err := NewFileFingerprintLeaser(dir).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lease.Release(context.Background())
+	if !lease.Expires().After(time.Now()) {
+		t.Error("new lease has already expired")
+	}
+	os.Remove(filepath.Join(dir, string(lease.Fingerprint())+".lock"))
+	if err := lease.Renew(context.Background()); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost once the lock file is gone, got %v", err)
+	}
+}
+
+func Test_FileLeasedGenerator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := NewFileFingerprintLeaser(t.TempDir())
+	a, err := WithLeasedFingerprint(ctx, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := WithLeasedFingerprint(ctx, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a.fingerprint) == string(b.fingerprint) {
+		t.Errorf("generators share the fingerprint `%s`", a.fingerprint)
+	}
+	if _, err := a.NewE(); err != nil {
+		t.Error(err)
+	}
+	cancel()
+	waitLost(t, a)
+	waitLost(t, b)
+}
@@ -0,0 +1,163 @@
+package puid
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a leaser handing out one fingerprint we can take away
+type memLeaser struct {
+	mtx      sync.Mutex
+	held     bool
+	lost     bool
+	fail     error
+	ttl      time.Duration
+	expires  time.Time
+	renewals int
+	released bool
+}
+
+func (m *memLeaser) Acquire(ctx context.Context) (FingerprintLease, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.held {
+		return nil, ErrNoFingerprint
+	}
+	m.held = true
+	m.expires = time.Now().Add(m.ttl)
+	return m, nil
+}
+
+func (m *memLeaser) Fingerprint() []byte { return []byte("l34s") }
+
+func (m *memLeaser) Expires() time.Time {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.expires
+}
+
+func (m *memLeaser) Renew(ctx context.Context) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.lost {
+		return ErrLeaseLost
+	}
+	if m.fail != nil {
+		return m.fail
+	}
+	m.renewals++
+	m.expires = time.Now().Add(m.ttl)
+	return nil
+}
+
+func (m *memLeaser) Release(ctx context.Context) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.held = false
+	m.released = true
+	return nil
+}
+
+func waitLost(t *testing.T, g *Generator) {
+	t.Helper()
+	select {
+	case <-g.LeaseLost():
+	case <-time.After(5 * time.Second):
+		t.Fatal("lease loss was not reported")
+	}
+}
+
+func Test_LeasedFingerprint(t *testing.T) {
+	m := &memLeaser{ttl: 30 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g, err := WithLeasedFingerprint(ctx, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := g.NewE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := Parse(s); id.Fingerprint != "l34s" {
+		t.Errorf("expected the leased fingerprint, got `%s`", s)
+	}
+	// it keeps working past the ttl, as the lease is renewed
+	time.Sleep(100 * time.Millisecond)
+	if _, err := g.NewE(); err != nil {
+		t.Fatal(err)
+	}
+	m.mtx.Lock()
+	if m.renewals == 0 {
+		t.Error("lease was not renewed")
+	}
+	m.lost = true
+	m.mtx.Unlock()
+
+	waitLost(t, g)
+	if _, err := g.NewE(); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost, got %v", err)
+	}
+	// clones share the lease
+	if _, err := g.WithPrefix("x").NewE(); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost from a clone, got %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected New to panic with a lost lease")
+		}
+	}()
+	g.New()
+}
+
+func Test_LeasedFingerprintRelease(t *testing.T) {
+	m := &memLeaser{ttl: time.Minute}
+	ctx, cancel := context.WithCancel(context.Background())
+	g, err := WithLeasedFingerprint(ctx, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WithLeasedFingerprint(ctx, m); !errors.Is(err, ErrNoFingerprint) {
+		t.Errorf("expected the leaser's error, got %v", err)
+	}
+	cancel()
+	waitLost(t, g)
+	if _, err := g.NewE(); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost after release, got %v", err)
+	}
+	m.mtx.Lock()
+	if !m.released {
+		t.Error("lease was not released")
+	}
+	m.mtx.Unlock()
+	// our own fingerprint means no lease to lose
+	if n := g.WithFingerprintBytes([]byte("abcd")); n.LeaseLost() != nil {
+		t.Error("generator with its own fingerprint should not have a lease")
+	} else if _, err := n.NewE(); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_LeasedFingerprintExpired(t *testing.T) {
+	// renewing fails but not with ErrLeaseLost, we carry on until it expires
+	m := &memLeaser{ttl: 50 * time.Millisecond, fail: errors.New("coordinator unreachable")}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g, err := WithLeasedFingerprint(ctx, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.NewE(); err != nil {
+		t.Errorf("lease should still be good, got %v", err)
+	}
+	waitLost(t, g)
+	if time.Now().Before(m.Expires()) {
+		t.Error("lease reported lost before it expired")
+	}
+	if _, err := g.NewE(); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost, got %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package puid
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoMmap = errors.New("puid: memory mapped files are not supported on this platform")
+
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	return nil, errNoMmap
+}
+
+func munmap(b []byte) error {
+	return errNoMmap
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package puid
+
+import (
+	"os"
+	"syscall"
+)
+
+// map size bytes of the file into memory, shared with other processes
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+func munmap(b []byte) error {
+	return syscall.Munmap(b)
+}
@@ -0,0 +1,73 @@
+package puid
+
+import "time"
+
+// What a generator with a monotonic counter does when more than
+// MAX_COUNTER ids are asked for in one millisecond
+type OverflowPolicy int
+
+const (
+	// move the timestamp on a millisecond, ahead of the clock
+	OverflowBump OverflowPolicy = iota
+	// wait for the clock to reach the next millisecond
+	OverflowWait
+)
+
+// Return a new generator like this one, but with the monotonic counter on or off.
+// With it on, the Counter is not used, instead the counter starts at 0 each
+// millisecond and counts up, so the (timestamp, counter) pair never repeats
+// and the ids sort as strings in the order they were created (the timestamp
+// is padded as in sortable mode). Monotonic generators built from the same
+// generator (e.g. two calls to the package level `WithMonotonicCounter`)
+// share the sequence, so the pair doesn't repeat across them either,
+// unless one of them is given another clock with `WithClock`.
+// See `WithOverflow` for what happens when the counter runs out within
+// a millisecond.
+func (g *Generator) WithMonotonicCounter(on bool) *Generator {
+	n := g.dup()
+	n.monotonic = on
+	n.setSequence()
+	return n
+}
+
+// Returns the default generator but with a monotonic counter
+func WithMonotonicCounter(on bool) *Generator {
+	return defaultGenerator.WithMonotonicCounter(on)
+}
+
+// Return a new generator like this one, but with the given overflow policy
+// for the monotonic counter
+func (g *Generator) WithOverflow(p OverflowPolicy) *Generator {
+	n := g.dup()
+	n.overflow = p
+	return n
+}
+
+// Returns the default generator but with the given overflow policy
+func WithOverflow(p OverflowPolicy) *Generator {
+	return defaultGenerator.WithOverflow(p)
+}
+
+// Returns the next (timestamp, counter) pair for the monotonic counter.
+// If the clock goes back we stay on the last timestamp and keep counting.
+func (g *Generator) nextMonotonic(now int64) (int64, int64) {
+	s := g.seq
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	switch {
+	case now > s.time:
+		s.time, s.counter = now, 0
+	case s.counter+1 < MAX_COUNTER:
+		s.counter++
+	case g.overflow == OverflowWait:
+		// everyone else would have to wait too, so we keep the lock
+		for now <= s.time {
+			time.Sleep(time.Duration(s.time-now+1) * time.Millisecond)
+			now = g.hammertime()
+		}
+		s.time, s.counter = now, 0
+	default:
+		s.time, s.counter = s.time+1, 0
+	}
+	return s.time, s.counter
+}
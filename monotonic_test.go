@@ -0,0 +1,95 @@
+package puid
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func Test_MonotonicCounterResets(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	g := NewGenerator(&Options{Clock: clock, MonotonicCounter: true})
+	ids := []string{}
+	for _, step := range []time.Duration{0, 0, 0, time.Millisecond, 0, -time.Second, 0, 2 * time.Second} {
+		clock.Advance(step)
+		ids = append(ids, g.New())
+	}
+	counters := []int64{}
+	for _, s := range ids {
+		id, err := g.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counters = append(counters, id.Counter)
+	}
+	// after the clock goes back we keep counting on the last timestamp
+	expected := []int64{0, 1, 2, 0, 1, 2, 3, 0}
+	for i := range expected {
+		if counters[i] != expected[i] {
+			t.Fatalf("unexpected counters %v, expected %v", counters, expected)
+		}
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("ids are not in generation order: %v", ids)
+	}
+}
+
+func Test_MonotonicOverflowBump(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	g := WithClock(clock).WithMonotonicCounter(true)
+	a, _ := g.Parse(g.New())
+	g.seq.counter = MAX_COUNTER - 1
+	b, _ := g.Parse(g.New())
+	c, _ := g.Parse(g.New())
+	if b.Counter != 0 || !b.Timestamp.Equal(a.Timestamp.Add(time.Millisecond)) {
+		t.Errorf("expected overflow to move on a millisecond, got %+v then %+v", a, b)
+	}
+	// and once the clock catches up we keep counting from there
+	clock.Advance(time.Millisecond)
+	d, _ := g.Parse(g.New())
+	if c.Counter != 1 || d.Counter != 2 || !d.Timestamp.Equal(b.Timestamp) {
+		t.Errorf("unexpected ids after overflow %+v then %+v", c, d)
+	}
+}
+
+func Test_MonotonicOverflowWait(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	g := WithClock(clock).WithMonotonicCounter(true).WithOverflow(OverflowWait)
+	a, _ := g.Parse(g.New())
+	g.seq.counter = MAX_COUNTER - 1
+	done := make(chan string)
+	go func() { done <- g.New() }()
+	select {
+	case s := <-done:
+		t.Fatalf("expected to wait for the next millisecond, got %s", s)
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(5 * time.Millisecond)
+	b, _ := g.Parse(<-done)
+	if b.Counter != 0 || !b.Timestamp.Equal(a.Timestamp.Add(5*time.Millisecond)) {
+		t.Errorf("expected the id from the clock's next millisecond, got %+v", b)
+	}
+}
+
+func Test_MonotonicSharedWithParent(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	parent := WithClock(clock)
+	a := parent.WithMonotonicCounter(true)
+	b := parent.WithMonotonicCounter(true).WithPrefix("b")
+	seen := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		for _, g := range []*Generator{a, b, a.WithSortable(true)} {
+			id, _ := g.Parse(g.New())
+			pair := id.Timestamp.String() + "/" + string(appendPaddedInt(nil, id.Counter, BLOCK))
+			if seen[pair] {
+				t.Fatalf("(timestamp, counter) pair %s issued twice", pair)
+			}
+			seen[pair] = true
+		}
+	}
+	// another clock is another timeline
+	other := a.WithClock(NewFakeClock(time.Unix(1400000000, 0)))
+	if id, _ := other.Parse(other.New()); id.Counter != 0 {
+		t.Errorf("expected a fresh sequence with a new clock, got %+v", id)
+	}
+}
@@ -0,0 +1,37 @@
+package puid
+
+import "errors"
+
+// The error from Observe on a generator that doesn't keep its ids in order
+var ErrNotOrdered = errors.New("puid: Observe needs a monotonic or sortable generator")
+
+// Tell the generator about an id from elsewhere (e.g. in a message from
+// another service), so every id it creates afterwards sorts after it,
+// even if the other machine's clock is ahead of ours.
+//
+// With a monotonic counter this makes the generator a hybrid logical clock:
+// the timestamp is the physical time unless we have seen a later one, and
+// the counter orders ids within it. It also works with a sortable generator.
+//
+// The id may have any prefix. Ids that fail validation, including those
+// further in the future than the generator's skew allows, are rejected
+// and do not move the clock.
+func (g *Generator) Observe(s string) error {
+	if !g.sortable && !g.monotonic {
+		return ErrNotOrdered
+	}
+	id, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	if err := g.checkTimestamp(s, id.Timestamp); err != nil {
+		return err
+	}
+	ts := id.Timestamp.UnixMilli()
+	g.seq.mtx.Lock()
+	if ts > g.seq.time || (ts == g.seq.time && id.Counter > g.seq.counter) {
+		g.seq.time, g.seq.counter = ts, id.Counter
+	}
+	g.seq.mtx.Unlock()
+	return nil
+}
@@ -0,0 +1,90 @@
+package puid
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_ObserveOrdersAfterRemoteIds(t *testing.T) {
+	local := NewFakeClock(time.Unix(1500000000, 0))
+	remote := NewFakeClock(time.Unix(1500000000, 0).Add(30 * time.Second))
+	a := WithClock(local).WithMonotonicCounter(true)
+	b := WithPrefix("b").WithClock(remote).WithMonotonicCounter(true)
+	for i := 0; i < 5; i++ {
+		a.New()
+		b.New()
+	}
+	seen := b.New()
+	if err := a.Observe(seen); err != nil {
+		t.Fatal(err)
+	}
+	after := a.New()
+	id, _ := a.Parse(after)
+	obs, _ := Parse(seen)
+	if !id.Timestamp.Equal(obs.Timestamp) || id.Counter != obs.Counter+1 {
+		t.Errorf("expected the id after `%s` to follow it, got `%s`", seen, after)
+	}
+	if after[1:] <= seen[1:] {
+		t.Errorf("`%s` does not sort after `%s`", after, seen)
+	}
+	// when our clock passes it we are back to physical time
+	local.Advance(time.Minute)
+	id, _ = a.Parse(a.New())
+	if !id.Timestamp.Equal(local.Now()) || id.Counter != 0 {
+		t.Errorf("expected physical time again, got %+v", id)
+	}
+	// observing an older id doesn't move us back
+	if err := a.Observe(seen); err != nil {
+		t.Fatal(err)
+	}
+	if next, _ := a.Parse(a.New()); !next.Timestamp.Equal(id.Timestamp) || next.Counter != 1 {
+		t.Errorf("observing an old id should not move the clock, got %+v", next)
+	}
+}
+
+func Test_ObserveSortable(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	g := WithClock(clock).WithCounter(dumbCounter(5)).WithSortable(true)
+	seen := WithClock(NewFakeClock(clock.Now().Add(time.Second))).WithCounter(dumbCounter(9)).New()
+	if err := g.Observe(seen); err != nil {
+		t.Fatal(err)
+	}
+	if after := g.New(); after <= seen {
+		t.Errorf("`%s` does not sort after `%s`", after, seen)
+	}
+}
+
+func Test_ObserveErrors(t *testing.T) {
+	if err := Default().Observe(New()); err != ErrNotOrdered {
+		t.Error("expected ErrNotOrdered from a plain generator")
+	}
+	// turning ordering off again means it is not ordered any more
+	for _, g := range []*Generator{
+		Default().WithSortable(true).WithSortable(false),
+		WithMonotonicCounter(true).WithMonotonicCounter(false),
+		WithMonotonicCounter(false),
+	} {
+		if err := g.Observe(New()); err != ErrNotOrdered {
+			t.Errorf("expected ErrNotOrdered with ordering turned off, got %v", err)
+		}
+		if g.seq != nil {
+			t.Error("generator with ordering turned off still has a sequence")
+		}
+	}
+	if err := WithMonotonicCounter(true).WithSortable(false).Observe(New()); err != nil {
+		t.Errorf("monotonic generator should still be ordered without sortable, got %v", err)
+	}
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	g := WithClock(clock).WithMonotonicCounter(true)
+	if err := g.Observe("nope"); !errors.Is(err, ErrLength) {
+		t.Errorf("expected ErrLength, got %v", err)
+	}
+	future := WithClock(NewFakeClock(clock.Now().Add(time.Hour))).New()
+	if err := g.Observe(future); !errors.Is(err, ErrTimestamp) {
+		t.Errorf("expected ErrTimestamp for an id beyond the skew, got %v", err)
+	}
+	if id, _ := g.Parse(g.New()); !id.Timestamp.Equal(clock.Now()) {
+		t.Errorf("a rejected id should not move the clock, got %s", id.Timestamp)
+	}
+}
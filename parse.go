@@ -0,0 +1,89 @@
+package puid
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// the parts of a puid after the prefix, the timestamp is 2 blocks
+// then counter, fingerprint and 2 blocks of random data.
+// The timestamp gets longer after 2059, we accept up to 12 digits which
+// is millions of years and still fits an int64.
+const (
+	timeLength    = 2 * BLOCK
+	bodyLength    = timeLength + 4*BLOCK
+	maxTimeLength = 12
+)
+
+// The errors a ParseError can wrap, check for them with `errors.Is`
+var (
+	ErrPrefix    = errors.New("puid: wrong prefix")
+	ErrLength    = errors.New("puid: bad length")
+	ErrCharacter = errors.New("puid: non-base36 character")
+)
+
+// The error returned when an id cannot be parsed
+type ParseError struct {
+	Input string // the id we tried to parse
+	Err   error  // one of the Err* values above
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error() + ": " + strconv.Quote(e.Input)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// The decoded segments of a puid
+type ID struct {
+	Prefix      string
+	Timestamp   time.Time
+	Counter     int64
+	Fingerprint string
+	Random      string
+}
+
+// Parse a puid created by this generator back into its segments.
+// The generator's prefix tells us where the timestamp starts, so we also
+// accept the longer timestamps of ids made after 2059.
+func (g *Generator) Parse(s string) (ID, error) {
+	if len(s) < len(g.prefix) || s[:len(g.prefix)] != string(g.prefix) {
+		return ID{}, &ParseError{Input: s, Err: ErrPrefix}
+	}
+	return parseBody(s, len(g.prefix), len(s)-len(g.prefix)-4*BLOCK)
+}
+
+// Parse a puid with any prefix back into its segments.
+// As we do not know the prefix, it is taken to be everything before
+// the fixed length timestamp, counter, fingerprint and random blocks.
+func Parse(s string) (ID, error) {
+	if len(s) < bodyLength {
+		return ID{}, &ParseError{Input: s, Err: ErrLength}
+	}
+	return parseBody(s, len(s)-bodyLength, timeLength)
+}
+
+// split out the segments of the id after the prefix of length n,
+// with a timestamp of length tl
+func parseBody(s string, n, tl int) (ID, error) {
+	if tl < timeLength || tl > maxTimeLength || len(s)-n != tl+4*BLOCK {
+		return ID{}, &ParseError{Input: s, Err: ErrLength}
+	}
+	body := s[n:]
+	if !isAllBase36([]byte(body)) {
+		return ID{}, &ParseError{Input: s, Err: ErrCharacter}
+	}
+	// these cannot fail, we have checked the length and the characters
+	ms, _ := strconv.ParseInt(body[:tl], BASE, 64)
+	c, _ := strconv.ParseInt(body[tl:tl+BLOCK], BASE, 64)
+	return ID{
+		Prefix:      s[:n],
+		Timestamp:   time.Unix(0, ms*int64(time.Millisecond)),
+		Counter:     c,
+		Fingerprint: body[tl+BLOCK : tl+2*BLOCK],
+		Random:      body[tl+2*BLOCK:],
+	}, nil
+}
@@ -0,0 +1,98 @@
+package puid
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_ParseDeterministic(t *testing.T) {
+	g := WithPrefix("x")
+	id, err := g.Parse("x100000001111ffffrrrrrrrr")
+	if err != nil {
+		t.Fatalf("unexpected error parsing id: %s", err)
+	}
+	if id.Prefix != "x" {
+		t.Errorf("unexpected prefix `%s`", id.Prefix)
+	}
+	// "10000000" in base36 is 78364164096 milliseconds
+	if !id.Timestamp.Equal(time.Unix(0, 78364164096*1e6)) {
+		t.Errorf("unexpected timestamp %s", id.Timestamp)
+	}
+	if id.Counter != 1+36+36*36+36*36*36 {
+		t.Errorf("unexpected counter %d", id.Counter)
+	}
+	if id.Fingerprint != "ffff" {
+		t.Errorf("unexpected fingerprint `%s`", id.Fingerprint)
+	}
+	if id.Random != "rrrrrrrr" {
+		t.Errorf("unexpected random `%s`", id.Random)
+	}
+}
+
+func Test_ParseRoundTrip(t *testing.T) {
+	for _, p := range []string{"", "p", "foo:"} {
+		g := WithPrefix(p).WithCounter(dumbCounter(1337)).WithFingerprintBytes([]byte("abcd"))
+		before := time.Now().Truncate(time.Millisecond)
+		s := g.New()
+		after := time.Now()
+		for _, parse := range []func(string) (ID, error){g.Parse, Parse} {
+			id, err := parse(s)
+			if err != nil {
+				t.Fatalf("unexpected error parsing `%s`: %s", s, err)
+			}
+			if id.Prefix != p || id.Counter != 1337 || id.Fingerprint != "abcd" || id.Random != s[len(s)-2*BLOCK:] {
+				t.Errorf("unexpected segments from `%s`: %+v", s, id)
+			}
+			if id.Timestamp.Before(before) || id.Timestamp.After(after) {
+				t.Errorf("unexpected timestamp from `%s`: %s", s, id.Timestamp)
+			}
+		}
+	}
+}
+
+func Test_ParseErrors(t *testing.T) {
+	g := WithPrefix("u")
+	tests := []struct {
+		s   string
+		err error
+	}{
+		{"o100000001111ffffrrrrrrrr", ErrPrefix},
+		{"", ErrPrefix},
+		{"u100000001111ffffrrrrrrr", ErrLength},
+		{"u1000000000000001111ffffrrrrrrrr", ErrLength},
+		{"u100000001111ffffrrrrrrrR", ErrCharacter},
+		{"u1000000-1111ffffrrrrrrrr", ErrCharacter},
+	}
+	for _, tt := range tests {
+		_, err := g.Parse(tt.s)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("parsing `%s`: expected %v, got %v", tt.s, tt.err, err)
+		}
+		var pe *ParseError
+		if !errors.As(err, &pe) || pe.Input != tt.s {
+			t.Errorf("parsing `%s`: expected a *ParseError, got %#v", tt.s, err)
+		}
+	}
+	if _, err := Parse("short"); !errors.Is(err, ErrLength) {
+		t.Errorf("expected ErrLength for short id, got %v", err)
+	}
+}
+
+func Test_ParseAfter2059(t *testing.T) {
+	// just past the last 8 digit timestamp
+	clock := NewFakeClock(time.UnixMilli(36*36*36*36*36*36*36*36 + 5))
+	for _, g := range []*Generator{WithPrefix("x").WithClock(clock), WithPrefix("x").WithClock(clock).WithSortable(true)} {
+		s := g.New()
+		if len(s) != 1+bodyLength+1 {
+			t.Errorf("expected a 9 digit timestamp in `%s`", s)
+		}
+		id, err := g.Parse(s)
+		if err != nil {
+			t.Fatalf("generator could not parse its own id `%s`: %s", s, err)
+		}
+		if !id.Timestamp.Equal(clock.Now()) || id.Prefix != "x" || id.String() != s {
+			t.Errorf("unexpected segments from `%s`: %+v", s, id)
+		}
+	}
+}
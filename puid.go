@@ -12,38 +12,68 @@ const (
 	MAX_COUNTER  = 1679616 //36^4 (full block size)
 )
 
-// this is just so we can be deterministic during testing
-// by replacing this function
-var getTime = func() time.Time {
-	return time.Now()
-}
-
-func hammertime() int64 {
-	return getTime().UnixNano() / int64(time.Millisecond)
+// the current time from the generator's clock in milliseconds
+func (g *Generator) hammertime() int64 {
+	return g.clock.Now().UnixNano() / int64(time.Millisecond)
 }
 
 // A puid generator
 type Generator struct {
-	fingerprint []byte
-	random      Random
-	counter     Counter
-	prefix      []byte
+	fingerprint  []byte
+	random       Random
+	counter      Counter
+	prefix       []byte
+	clock        Clock
+	maxSkew      time.Duration
+	sortable     bool
+	seq          *sequence
+	monoSeq      *sequence // shared by monotonic clones
+	moduloRandom bool
+	monotonic    bool
+	overflow     OverflowPolicy
+	regression   *regressionTracker
+	lease        *leaseState
 }
 
 // These are the options you can customize should you want
 type Options struct {
-	Fingerprint []byte  // this is the fingerprint for this host
-	Random      Random  // this is the source of randomness
-	Counter     Counter // this is the increasing counter
-	Prefix      []byte  // this is the prefix ("c" in `cuid`)
+	Fingerprint []byte   // this is the fingerprint for this host
+	Random      Random   // this is the source of randomness
+	Counter     Counter  // this is the increasing counter
+	CounterE    CounterE // or one that can fail, used instead of Counter if given
+	ZeroCounter bool     // start the default counter at 0, not a random offset
+	Prefix      []byte   // this is the prefix ("c" in `cuid`)
+	Clock       Clock    // this is where the timestamps come from
+
+	// how far in the future a timestamp may be before Validate rejects it
+	MaxSkew time.Duration
+
+	// make ids from this generator sort in the order they were created
+	// see `WithSortable`
+	Sortable bool
+
+	// use the original byte % 36 mapping for the random block, which is
+	// slightly biased but gives the same ids from the same Random as before
+	ModuloRandom bool
+
+	// reset the counter each millisecond instead of using Counter
+	// see `WithMonotonicCounter` and `WithOverflow`
+	MonotonicCounter bool
+	Overflow         OverflowPolicy
+
+	// what to do when the clock goes backwards, and a hook to hear about it
+	// see `WithRegressionPolicy` and `WithRegressionHook`
+	Regression   RegressionPolicy
+	OnRegression func(last, now time.Time)
 }
 
 // Spit out a new puid from the generator, raw bytes
 func (g *Generator) Bytes() []byte {
 	// the buffer is going to be about len(prefix) + 6*BLOCK long
 	// that depends on how big a timestamp can get.
-	// Timestamps will be 9 digits of base36 after: Fri Apr 22 5188 12:04:28 GMT+0100 (BST)
-	// so we can probably ignore that.
+	// Timestamps will be 9 digits of base36 after: Sun May 25 2059 17:38:27 GMT
+	// (and 10 after: Fri Apr 22 5188 12:04:28 GMT+0100 (BST))
+	// so we can probably ignore that for a while.
 	// also they were only 7 digits or les before: Mon Jun 26 1972 00:49:24 GMT+0100 (BST)
 	// so we can pretty much guarrantee that the length of an ID
 	// is prefix + 8 + 4*BLOCK
@@ -57,29 +87,87 @@ func Bytes() []byte {
 }
 
 // Append the bytes of a puid to the given buffer
+// panics if the Random or a CounterE fails, use AppendBytesE to handle that
 func (g *Generator) AppendBytes(buff []byte) []byte {
 	if buff == nil {
 		panic("AppendBytes() called with nil byte slice")
 	}
+	buff, err := g.AppendBytesE(buff)
+	if err != nil {
+		panic(err)
+	}
+	return buff
+}
+
+// Append the bytes of a puid to the given buffer using the default generator
+func AppendBytes(b []byte) []byte {
+	return defaultGenerator.AppendBytes(b)
+}
+
+// Append the bytes of a puid to the given buffer, returning an error
+// if the Random or a CounterE fails, or the clock went back with
+// RegressionError. On error the buffer is returned
+// as it was given.
+func (g *Generator) AppendBytesE(buff []byte) ([]byte, error) {
+	if buff == nil {
+		panic("AppendBytesE() called with nil byte slice")
+	}
+	start := len(buff)
+	if g.lease != nil {
+		if err := g.lease.check(); err != nil {
+			return buff, err
+		}
+	}
+	ts, err := g.timestamp()
+	if err != nil {
+		return buff, err
+	}
+	var c int64
+	switch {
+	case g.monotonic:
+		ts, c = g.nextMonotonic(ts)
+	case g.sortable:
+		// make sure we only move forward
+		ts, c, err = g.nextSortable(ts)
+	default:
+		c, err = g.nextCounter()
+	}
+	if err != nil {
+		return buff, err
+	}
 	// set the prefix
 	buff = append(buff, g.prefix...)
-	// timestamp is not padded an 8 digits in all likelyhood (see previous comment)
-	buff = strconv.AppendInt(buff, hammertime(), BASE)
+	if g.monotonic || g.sortable {
+		// we have ordered them already, pad the time so that stays true as strings
+		buff = appendPaddedInt(buff, ts, timeLength)
+	} else {
+		// timestamp is not padded an 8 digits in all likelyhood (see previous comment)
+		buff = strconv.AppendInt(buff, ts, BASE)
+	}
 	// now the counter
-	buff = appendPaddedInt(buff, g.counter.Next(), BLOCK)
+	buff = appendPaddedInt(buff, c, BLOCK)
 	// then the fingerprint (we clamped it to BLOCK bytes)
 	buff = append(buff, g.fingerprint...)
 	// now the random data
-	buff = appendRandomBase36(buff, g.random, BLOCK*2)
-	return buff
+	if g.moduloRandom {
+		buff, err = appendRandomBase36(buff, g.random, BLOCK*2)
+	} else {
+		buff, err = appendUnbiasedBase36(buff, g.random, BLOCK*2)
+	}
+	if err != nil {
+		return buff[:start], err
+	}
+	return buff, nil
 }
 
-// Append the bytes of a puid to the given buffer using the default generator
-func AppendBytes(b []byte) []byte {
-	return defaultGenerator.AppendBytes(b)
+// Append the bytes of a puid to the given buffer using the default generator,
+// returning any error
+func AppendBytesE(b []byte) ([]byte, error) {
+	return defaultGenerator.AppendBytesE(b)
 }
 
 // Generate an puid as a string
+// panics if the Random or a CounterE fails, use NewE to handle that
 func (g *Generator) New() string {
 	return string(g.Bytes())
 }
@@ -89,17 +177,29 @@ func New() string {
 	return defaultGenerator.New()
 }
 
+// Generate an puid as a string, returning an error if the Random
+// or a CounterE fails rather than panicking
+func (g *Generator) NewE() (string, error) {
+	b, err := g.AppendBytesE(make([]byte, 0, len(g.prefix)+8+4*BLOCK))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Returns an puid from the default generator, or an error
+func NewE() (string, error) {
+	return defaultGenerator.NewE()
+}
+
 // Create a clone of this generator but with the given Counter
 func (g *Generator) WithCounter(c Counter) *Generator {
 	if c == nil {
 		panic("WithCounter called with nil Counter")
 	}
-	return &Generator{
-		fingerprint: g.fingerprint,
-		counter:     c,
-		random:      g.random,
-		prefix:      g.prefix,
-	}
+	n := g.dup()
+	n.counter = c
+	return n
 }
 
 // create an id generator from the default one, but with the given Counter
@@ -107,18 +207,28 @@ func WithCounter(c Counter) *Generator {
 	return defaultGenerator.WithCounter(c)
 }
 
+// Create a clone of this generator but with the given CounterE
+func (g *Generator) WithCounterE(c CounterE) *Generator {
+	if c == nil {
+		panic("WithCounterE called with nil CounterE")
+	}
+	return g.WithCounter(errCounter{c})
+}
+
+// create an id generator from the default one, but with the given CounterE
+func WithCounterE(c CounterE) *Generator {
+	return defaultGenerator.WithCounterE(c)
+}
+
 // Return a new generator like this one, but with a different source
 // of randomness
 func (g *Generator) WithRandom(r Random) *Generator {
 	if r == nil {
 		panic("WithRandom called with nil Random")
 	}
-	return &Generator{
-		fingerprint: g.fingerprint,
-		counter:     g.counter,
-		random:      r,
-		prefix:      g.prefix,
-	}
+	n := g.dup()
+	n.random = r
+	return n
 }
 
 // Returns a clone of the default generator with the given Random-ness source
@@ -126,15 +236,25 @@ func WithRandom(r Random) *Generator {
 	return defaultGenerator.WithRandom(r)
 }
 
+// Return a new generator like this one, but turning the original byte % 36
+// mapping of random data on or off (see `Options.ModuloRandom`)
+func (g *Generator) WithModuloRandom(on bool) *Generator {
+	n := g.dup()
+	n.moduloRandom = on
+	return n
+}
+
+// Returns a clone of the default generator using the byte % 36 random mapping
+func WithModuloRandom(on bool) *Generator {
+	return defaultGenerator.WithModuloRandom(on)
+}
+
 // Return a new generator like this one, but with a different prefix
 // remember that cuid's a supposed to be portable/url safe/start with 'a-z'
 func (g *Generator) WithPrefixBytes(prefix []byte) *Generator {
-	return &Generator{
-		fingerprint: g.fingerprint,
-		counter:     g.counter,
-		random:      g.random,
-		prefix:      prefix,
-	}
+	n := g.dup()
+	n.prefix = prefix
+	return n
 }
 
 // Returns the default generator but with the given []byte prefix
@@ -169,13 +289,10 @@ func (g *Generator) WithFingerprintBytes(b []byte) *Generator {
 	if b == nil {
 		panic("*(puid.Generator).WithFingerprintBytes called with nil byte slice")
 	}
-	b = massageFingerprint(b)
-	return &Generator{
-		fingerprint: b,
-		counter:     g.counter,
-		random:      g.random,
-		prefix:      g.prefix,
-	}
+	n := g.dup()
+	n.fingerprint = massageFingerprint(b)
+	n.lease = nil
+	return n
 }
 
 // Returns the default generator but with the given fingerprint []byte
@@ -195,9 +312,53 @@ func WithFingerprint(str string, num int64) *Generator {
 	return defaultGenerator.WithFingerprint(str, num)
 }
 
+// Return a new generator like this one, but taking time from the given Clock.
+// A different clock means a different timeline, so the new generator keeps
+// its ids in order and tracks clock regressions (if it does) separately
+// from this one.
+func (g *Generator) WithClock(c Clock) *Generator {
+	if c == nil {
+		panic("WithClock called with nil Clock")
+	}
+	n := g.dup()
+	n.clock = c
+	n.monoSeq = newSequence()
+	n.setSequence()
+	if g.regression != nil {
+		n.regression = newRegressionTracker(g.regression.policy, g.regression.hook)
+	}
+	return n
+}
+
+// Returns a clone of the default generator with the given Clock
+func WithClock(c Clock) *Generator {
+	return defaultGenerator.WithClock(c)
+}
+
+// Return a new generator like this one, but allowing timestamps up to
+// d in the future when validating ids
+func (g *Generator) WithMaxSkew(d time.Duration) *Generator {
+	n := g.dup()
+	n.maxSkew = d
+	return n
+}
+
+// Returns the default generator but with the given validation skew
+func WithMaxSkew(d time.Duration) *Generator {
+	return defaultGenerator.WithMaxSkew(d)
+}
+
+// a shallow copy for the With* functions to modify
+func (g *Generator) dup() *Generator {
+	n := *g
+	return &n
+}
+
 // these are the default options
 var (
-	defaultPrefix      = []byte{'p'}
+	defaultClock       Clock = systemClock{}
+	defaultMaxSkew           = time.Minute
+	defaultPrefix            = []byte{'p'}
 	defaultFingerprint []byte
 	defaultGenerator   *Generator
 )
@@ -225,18 +386,32 @@ func Default() *Generator {
 // Create a new puid generator
 func NewGenerator(o *Options) *Generator {
 	if o == nil {
+		r := getDefaultRandom()
 		return &Generator{
 			fingerprint: clone(defaultFingerprint),
-			random:      getDefaultRandom(),
-			counter:     getDefaultCounter(),
+			random:      r,
+			counter:     getDefaultCounter(r, false),
 			prefix:      clone(defaultPrefix),
+			clock:       defaultClock,
+			maxSkew:     defaultMaxSkew,
+			monoSeq:     newSequence(),
 		}
 	}
 	g := &Generator{
-		fingerprint: o.Fingerprint,
-		random:      o.Random,
-		counter:     o.Counter,
-		prefix:      o.Prefix,
+		fingerprint:  o.Fingerprint,
+		random:       o.Random,
+		counter:      o.Counter,
+		prefix:       o.Prefix,
+		clock:        o.Clock,
+		maxSkew:      o.MaxSkew,
+		sortable:     o.Sortable,
+		moduloRandom: o.ModuloRandom,
+		monotonic:    o.MonotonicCounter,
+		overflow:     o.Overflow,
+		regression:   newRegressionTracker(o.Regression, o.OnRegression),
+	}
+	if o.CounterE != nil {
+		g.counter = errCounter{o.CounterE}
 	}
 	g.fingerprint = massageFingerprint(g.fingerprint)
 
@@ -244,11 +419,19 @@ func NewGenerator(o *Options) *Generator {
 		g.random = getDefaultRandom() // note we call this again to ensure it is a *new* random source
 	}
 	if g.counter == nil {
-		g.counter = getDefaultCounter() // note we call this again to get a NEW counter
+		g.counter = getDefaultCounter(g.random, o.ZeroCounter) // note we call this again to get a NEW counter
 	}
 	if g.prefix == nil {
 		g.prefix = clone(defaultPrefix)
 	}
+	if g.clock == nil {
+		g.clock = defaultClock
+	}
+	if g.maxSkew == 0 {
+		g.maxSkew = defaultMaxSkew
+	}
+	g.monoSeq = newSequence()
+	g.setSequence()
 	return g
 }
 
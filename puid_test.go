@@ -47,18 +47,14 @@ func Test_Deterministic(t *testing.T) {
 		random:      badRandom(27), // 27 == "r" in base36
 		fingerprint: []byte("ffff"),
 		counter:     dumbCounter(1 + 36 + 36*36 + 36*36*36), // 1111
+		// use a fixed known time (actually the lowest possible puid value with current block size: Mon Jun 26 1972 00:49:24 GMT+0100 (BST))
+		// which in milliseconds unixtime and base36 is "10000000" in milliseconds it is 78364164096 so we put it in as nano
+		clock: NewFakeClock(time.Unix(0, 78364164096*1e6)),
 	}
-	// replace the time with a fixed known value (actually the lowest possible puid value with current block size: Mon Jun 26 1972 00:49:24 GMT+0100 (BST))
-	// which in milliseconds unixtime and base36 is "10000000" in milliseconds it is 78364164096 so we put it in as nano
-	ft := getTime
-	getTime = func() time.Time { return time.Unix(0, 78364164096*1e6) }
 
 	// now grab the id
 	actual := g.New()
 
-	//put time back
-	getTime = ft
-
 	// prefix + time (2 blocks) + counter (1 block) + fingerprint (1 block) + random (2 blocks)
 	expected := "x100000001111ffffrrrrrrrr"
 	if actual != expected {
@@ -66,6 +62,27 @@ func Test_Deterministic(t *testing.T) {
 	}
 }
 
+// the same, but the sortable timestamp is padded so must come out the same
+func Test_DeterministicSortable(t *testing.T) {
+	g := &Generator{
+		prefix:      []byte{'x'},
+		random:      badRandom(27),
+		fingerprint: []byte("ffff"),
+		counter:     dumbCounter(1 + 36 + 36*36 + 36*36*36),
+		clock:       NewFakeClock(time.Unix(0, 78364164096*1e6)),
+		sortable:    true,
+		seq:         newSequence(),
+	}
+	actual := g.New()
+	expected := "x100000001111ffffrrrrrrrr"
+	if actual != expected {
+		t.Errorf("deterministic sortable test failed. expected `%s`, got `%s`", expected, actual)
+	}
+	if _, err := g.Parse(actual); err != nil {
+		t.Errorf("generator could not parse its own id: %s", err)
+	}
+}
+
 func Test_PanicIfAppendBytesCalledWithNilSlice(t *testing.T) {
 	defer func() {
 		if err := recover(); err == nil {
@@ -97,6 +114,29 @@ func Benchmark_PuidAppendBytes(b *testing.B) {
 	}
 }
 
+func Benchmark_PuidAppendBytesParallel(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		buff := make([]byte, 0, 9+4*BLOCK)
+		for pb.Next() {
+			buff = AppendBytes(buff[:0])
+		}
+	})
+}
+
+// the same, but with the mutex protected counter and random we used to use
+func Benchmark_PuidAppendBytesParallelLocked(b *testing.B) {
+	g := NewGenerator(&Options{
+		Counter: &counterMutex{},
+		Random:  NewChaCha8Random([32]byte{}),
+	})
+	b.RunParallel(func(pb *testing.PB) {
+		buff := make([]byte, 0, 9+4*BLOCK)
+		for pb.Next() {
+			buff = g.AppendBytes(buff[:0])
+		}
+	})
+}
+
 func Benchmark_PuidInCuidMode(b *testing.B) {
 	c := Cuid()
 	for i := 0; i < b.N; i++ {
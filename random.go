@@ -1,19 +1,28 @@
 package puid
 
 import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
 	"math/rand"
+	randv2 "math/rand/v2"
 	"sync"
 	"time"
 )
 
-// the default random is the one here
+// the default random is a set of ChaCha8 streams seeded from crypto/rand
+// it benchmarks as fast as math/rand, and is not predictable
 func getDefaultRandom() Random {
-	return NewMathRandom(nil)
+	return newShardedRandom()
 }
 
 // The randomness source should fill the buffer
 // with random data. I initially made an implementation that used
-// `crypto/rand` but it was waaaay slow and unnecessary
+// `crypto/rand` but it was waaaay slow and unnecessary, if you do need
+// unguessable ids use `NewCryptoRandom` which buffers the reads.
+// Short reads are retried, an error fails the id (see `NewE`)
 type Random interface {
 	Read([]byte) (int, error)
 }
@@ -24,13 +33,119 @@ type mathRandom struct {
 }
 
 // A new source of psuedo-randomness for our Generator
+// with a nil source, it is seeded from crypto/rand
 func NewMathRandom(source rand.Source) Random {
 	if source == nil {
-		source = rand.NewSource(time.Now().UnixNano())
+		source = rand.NewSource(cryptoSeed())
 	}
 	return &mathRandom{r: rand.New(source)}
 }
 
+// a seed that will not be the same as another process started at the
+// same time, which a timestamp would be on a coarse clock
+func cryptoSeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		// it is as good as we've got
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+type chachaRandom struct {
+	r   *randv2.ChaCha8
+	mtx sync.Mutex
+}
+
+// A reproducible source of randomness for our Generator, the same seed
+// always gives the same stream. It is the same as NewChaCha8Random.
+func NewSeededRandom(seed [32]byte) Random {
+	return NewChaCha8Random(seed)
+}
+
+// A ChaCha8 stream from math/rand/v2 as our source of randomness, it is
+// fast and unlike math/rand not predictable from its output without the seed
+func NewChaCha8Random(seed [32]byte) Random {
+	return &chachaRandom{r: randv2.NewChaCha8(seed)}
+}
+
+// implements the Random interface
+func (c *chachaRandom) Read(b []byte) (n int, e error) {
+	c.mtx.Lock()
+	n = readUint64s(c.r, b)
+	c.mtx.Unlock()
+	return
+}
+
+// ChaCha8 streams kept in a sync.Pool, which keeps its items per P
+// so concurrent generators don't all queue on one mutex
+type shardedRandom struct {
+	pool sync.Pool
+}
+
+func newShardedRandom() *shardedRandom {
+	s := &shardedRandom{}
+	s.pool.New = func() interface{} {
+		var seed [32]byte
+		if _, err := crand.Read(seed[:]); err != nil {
+			// fall back to something, at least it won't match other processes
+			binary.LittleEndian.PutUint64(seed[:], uint64(cryptoSeed()))
+		}
+		return randv2.NewChaCha8(seed)
+	}
+	return s
+}
+
+// implements the Random interface
+func (s *shardedRandom) Read(b []byte) (int, error) {
+	r := s.pool.Get().(*randv2.ChaCha8)
+	n := readUint64s(r, b)
+	s.pool.Put(r)
+	return n, nil
+}
+
+// A PCG source from math/rand/v2 as our source of randomness
+// it is very fast, but predictable, like math/rand
+func NewPCGRandom(seed1, seed2 uint64) Random {
+	return NewRandV2(randv2.NewPCG(seed1, seed2))
+}
+
+type sourceRandom struct {
+	src randv2.Source
+	mtx sync.Mutex
+}
+
+// Any math/rand/v2 Source as our source of randomness
+func NewRandV2(src randv2.Source) Random {
+	if c, ok := src.(*randv2.ChaCha8); ok {
+		// the same as NewChaCha8Random
+		return &chachaRandom{r: c}
+	}
+	return &sourceRandom{src: src}
+}
+
+// implements the Random interface
+func (s *sourceRandom) Read(b []byte) (n int, e error) {
+	s.mtx.Lock()
+	n = readUint64s(s.src, b)
+	s.mtx.Unlock()
+	return
+}
+
+// fill b from the source 8 bytes at a time, least significant first.
+// (ChaCha8 has a Read method, but only since go 1.23)
+func readUint64s(src randv2.Source, b []byte) (n int) {
+	for n < len(b) {
+		v := src.Uint64()
+		for i := 0; i < 8 && n < len(b); i++ {
+			b[n] = byte(v)
+			v >>= 8
+			n++
+		}
+	}
+	return
+}
+
 // implements the Random interface
 func (m *mathRandom) Read(b []byte) (n int, e error) {
 	m.mtx.Lock()
@@ -39,10 +154,47 @@ func (m *mathRandom) Read(b []byte) (n int, e error) {
 	return
 }
 
-// append and return
-func appendRandomBase36(b []byte, r Random, count int) []byte {
+// append and return, a short read is an error
+// This is the original mapping, which uses base36convert so '0'-'3' are
+// slightly more likely than the rest (8/256 vs 7/256)
+func appendRandomBase36(b []byte, r Random, count int) ([]byte, error) {
 	t := make([]byte, count)
-	r.Read(t)
+	if _, err := io.ReadFull(r, t); err != nil {
+		return b, fmt.Errorf("puid: reading random data: %w", err)
+	}
 	base36convert(t)
-	return append(b, t...)
+	return append(b, t...), nil
+}
+
+// bytes at or above the largest multiple of BASE that fits in a byte
+// are thrown away, so every character is equally likely
+const unbiasedLimit = 256 - 256%BASE
+
+// how many reads we make before deciding the Random is broken
+const maxUnbiasedReads = 32
+
+// The error when the Random never gives bytes we can use without bias
+var ErrRandomExhausted = errors.New("puid: random source gave no usable bytes")
+
+// append count unbiased base36 characters, by rejection sampling
+func appendUnbiasedBase36(b []byte, r Random, count int) ([]byte, error) {
+	// read a couple of spare bytes, so we rarely need a second read
+	t := make([]byte, count+2)
+	for i := 0; count > 0; i++ {
+		if i == maxUnbiasedReads {
+			return b, ErrRandomExhausted
+		}
+		if _, err := io.ReadFull(r, t); err != nil {
+			return b, fmt.Errorf("puid: reading random data: %w", err)
+		}
+		for _, c := range t {
+			if c < unbiasedLimit {
+				b = append(b, base36chars[c%BASE])
+				if count--; count == 0 {
+					break
+				}
+			}
+		}
+	}
+	return b, nil
 }
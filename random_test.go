@@ -1,6 +1,12 @@
 package puid
 
 import (
+	"bytes"
+	crand "crypto/rand"
+	"errors"
+	"math/rand"
+	randv2 "math/rand/v2"
+	"sync"
 	"testing"
 )
 
@@ -47,3 +53,206 @@ func Test_NilRandomCausesPanic(t *testing.T) {
 	}()
 	WithRandom(nil)
 }
+
+// returns at most one byte per read, then fails
+type flakyRandom struct {
+	ok int
+}
+
+func (f *flakyRandom) Read(b []byte) (int, error) {
+	if f.ok == 0 || len(b) == 0 {
+		return 0, errors.New("flaky")
+	}
+	f.ok--
+	b[0] = 1
+	return 1, nil
+}
+
+func Test_RandomErrors(t *testing.T) {
+	// short reads are fine as long as we get enough in the end
+	// (the unbiased conversion reads a couple of spare bytes)
+	g := WithRandom(&flakyRandom{ok: 2*BLOCK + 2})
+	if id, err := g.NewE(); err != nil || id[9+BLOCK*2:] != "11111111" {
+		t.Errorf("unexpected result with short reads: %q %v", id, err)
+	}
+	// but not when it runs out
+	if id, err := g.NewE(); err == nil || id != "" {
+		t.Errorf("expected an error from a failing Random, got %q", id)
+	}
+	func() {
+		defer func() {
+			if err := recover(); err == nil {
+				t.Error("expected New to panic with a failing Random")
+			}
+		}()
+		g.New()
+	}()
+}
+
+// the chi-square statistic for how far the counts of each base36
+// character are from a flat distribution
+func chiSquare(b []byte) float64 {
+	var counts [BASE]float64
+	for _, c := range b {
+		if c <= '9' {
+			counts[c-'0']++
+		} else {
+			counts[c-'a'+10]++
+		}
+	}
+	expected := float64(len(b)) / BASE
+	var x2 float64
+	for _, n := range counts {
+		x2 += (n - expected) * (n - expected) / expected
+	}
+	return x2
+}
+
+// the critical value for 35 degrees of freedom at p = 0.001
+const chiSquareCritical = 66.62
+
+func Test_UnbiasedRandomIsFlat(t *testing.T) {
+	draws := 2000000
+	r := NewMathRandom(rand.NewSource(1))
+	b, err := appendUnbiasedBase36(make([]byte, 0, draws), r, draws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x2 := chiSquare(b); x2 > chiSquareCritical {
+		t.Errorf("unbiased random distribution is not flat, chi-square = %.2f", x2)
+	}
+	// and the old mapping should fail the same test, or it isn't much of a test
+	b, _ = appendRandomBase36(b[:0], r, draws)
+	if x2 := chiSquare(b); x2 < chiSquareCritical {
+		t.Errorf("expected the modulo mapping to be measurably biased, chi-square = %.2f", x2)
+	}
+}
+
+func Test_ModuloRandom(t *testing.T) {
+	// 36 is "0" with the modulo mapping, but it is a perfectly good byte either way
+	for _, g := range []*Generator{WithRandom(badRandom(36)).WithModuloRandom(true), NewGenerator(&Options{Random: badRandom(36), ModuloRandom: true})} {
+		confirmDumbRandom(t, g, "00000000")
+	}
+	confirmDumbRandom(t, WithRandom(badRandom(36)), "00000000")
+	// 252 and up are thrown away without it
+	confirmDumbRandom(t, WithRandom(badRandom(252)).WithModuloRandom(true), "00000000")
+	if _, err := WithRandom(badRandom(252)).NewE(); err != ErrRandomExhausted {
+		t.Errorf("expected ErrRandomExhausted from a Random with nothing usable, got %v", err)
+	}
+}
+
+func Test_SeededRandom(t *testing.T) {
+	read := func(r Random) string {
+		b := make([]byte, 32)
+		r.Read(b)
+		return string(b)
+	}
+	seed := [32]byte{1, 2, 3}
+	a, b := NewSeededRandom(seed), NewSeededRandom(seed)
+	if read(a) != read(b) || read(a) != read(b) {
+		t.Error("the same seed should give the same stream")
+	}
+	seed[31] = 1
+	if read(NewSeededRandom(seed)) == read(NewSeededRandom([32]byte{1, 2, 3})) {
+		t.Error("different seeds should give different streams")
+	}
+}
+
+func Test_ConcurrentGeneratorsHaveDistinctRandom(t *testing.T) {
+	n := 200
+	streams := make(chan string, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			g := NewGenerator(nil)
+			b := make([]byte, 16)
+			g.random.Read(b)
+			streams <- string(b)
+		}()
+	}
+	seen := map[string]struct{}{}
+	for i := 0; i < n; i++ {
+		s := <-streams
+		if _, dupe := seen[s]; dupe {
+			t.Fatalf("two generators created at the same time share a random stream")
+		}
+		seen[s] = struct{}{}
+	}
+}
+
+func Test_RandV2Sources(t *testing.T) {
+	sources := map[string]Random{
+		"chacha8": NewChaCha8Random([32]byte{42}),
+		"pcg":     NewPCGRandom(1, 2),
+		"v2":      NewRandV2(randv2.NewPCG(3, 4)),
+	}
+	for name, r := range sources {
+		// an odd size, so we use part of a Uint64
+		b := make([]byte, 13)
+		if n, err := r.Read(b); n != len(b) || err != nil {
+			t.Errorf("%s: unexpected read result %d %v", name, n, err)
+		}
+		b, err := appendUnbiasedBase36(nil, r, 1000000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x2 := chiSquare(b); x2 > chiSquareCritical {
+			t.Errorf("%s: distribution is not flat, chi-square = %.2f", name, x2)
+		}
+	}
+	// and the default, from more than one goroutine
+	r := newShardedRandom()
+	var wg sync.WaitGroup
+	results := make([][]byte, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = appendUnbiasedBase36(nil, r, 250000)
+		}(i)
+	}
+	wg.Wait()
+	if x2 := chiSquare(bytes.Join(results, nil)); x2 > chiSquareCritical {
+		t.Errorf("sharded: distribution is not flat, chi-square = %.2f", x2)
+	}
+	// the ChaCha8 source should be the same as NewChaCha8Random
+	if _, ok := NewRandV2(randv2.NewChaCha8([32]byte{})).(*chachaRandom); !ok {
+		t.Error("expected a *rand.ChaCha8 to be a chachaRandom")
+	}
+}
+
+//
+// Benchmarks
+//
+
+func Benchmark_MathRandom(b *testing.B) {
+	benchmarkRandom(b, NewMathRandom(nil))
+}
+
+func Benchmark_ChaCha8Random(b *testing.B) {
+	benchmarkRandom(b, NewChaCha8Random([32]byte{}))
+}
+
+func Benchmark_PCGRandom(b *testing.B) {
+	benchmarkRandom(b, NewPCGRandom(1, 2))
+}
+
+func Benchmark_ShardedRandom(b *testing.B) {
+	benchmarkRandom(b, newShardedRandom())
+}
+
+func Benchmark_CryptoRandom(b *testing.B) {
+	benchmarkRandom(b, NewCryptoRandom())
+}
+
+// what we would get reading crypto/rand for every id
+func Benchmark_CryptoRandomUnbuffered(b *testing.B) {
+	benchmarkRandom(b, crand.Reader)
+}
+
+func benchmarkRandom(b *testing.B, r Random) {
+	// the unbiased conversion reads 2*BLOCK+2 bytes per id
+	buff := make([]byte, 2*BLOCK+2)
+	for i := 0; i < b.N; i++ {
+		r.Read(buff)
+	}
+}
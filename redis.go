@@ -0,0 +1,337 @@
+package puid
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// An error reply from the redis server
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "puid: redis: " + string(e)
+}
+
+var errBadReply = errors.New("puid: redis: malformed reply")
+
+// just enough of a RESP (redis protocol) client for our counters,
+// keeping a few connections open between commands
+type respClient struct {
+	addr    string
+	maxIdle int
+	idle    []*respConn
+	mtx     sync.Mutex
+}
+
+// how many idle connections a client keeps by default
+const defaultRedisMaxIdle = 4
+
+func newRespClient(addr string, maxIdle int) *respClient {
+	if maxIdle < 1 {
+		maxIdle = defaultRedisMaxIdle
+	}
+	return &respClient{addr: addr, maxIdle: maxIdle}
+}
+
+type respConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *respClient) dial(ctx context.Context) (*respConn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	return &respConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// an idle connection, or nil if there are none
+func (c *respClient) get() *respConn {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if len(c.idle) == 0 {
+		return nil
+	}
+	conn := c.idle[len(c.idle)-1]
+	c.idle = c.idle[:len(c.idle)-1]
+	return conn
+}
+
+func (c *respClient) put(conn *respConn) {
+	conn.SetDeadline(time.Time{})
+	c.mtx.Lock()
+	if len(c.idle) < c.maxIdle {
+		c.idle = append(c.idle, conn)
+		conn = nil
+	}
+	c.mtx.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// send a command and read the reply. If an idle connection turns out to be
+// dead (the server may have closed it) we try again once on a new one, which
+// might run the command twice. That is fine for counters, we skip a value.
+func (c *respClient) do(ctx context.Context, args ...string) (interface{}, error) {
+	if conn := c.get(); conn != nil {
+		v, err := c.doOn(ctx, conn, args)
+		if err == nil || isRedisError(err) || ctx.Err() != nil {
+			return v, err
+		}
+	}
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.doOn(ctx, conn, args)
+}
+
+// run the command, keeping the connection unless it failed
+func (c *respClient) doOn(ctx context.Context, conn *respConn, args []string) (interface{}, error) {
+	v, err := conn.do(ctx, args...)
+	if err != nil && !isRedisError(err) {
+		// we don't know what state it is in
+		conn.Close()
+		return nil, err
+	}
+	c.put(conn)
+	return v, err
+}
+
+// close all the idle connections
+func (c *respClient) close() {
+	c.mtx.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.mtx.Unlock()
+	for _, conn := range idle {
+		conn.Close()
+	}
+}
+
+func isRedisError(err error) bool {
+	_, ok := err.(RedisError)
+	return ok
+}
+
+func (conn *respConn) do(ctx context.Context, args ...string) (interface{}, error) {
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	if _, err := conn.Write(appendCommand(nil, args)); err != nil {
+		return nil, err
+	}
+	return readReply(conn.r)
+}
+
+// commands are sent as an array of bulk strings
+func appendCommand(b []byte, args []string) []byte {
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(len(args)), 10)
+	b = append(b, '\r', '\n')
+	for _, a := range args {
+		b = append(b, '$')
+		b = strconv.AppendInt(b, int64(len(a)), 10)
+		b = append(b, '\r', '\n')
+		b = append(b, a...)
+		b = append(b, '\r', '\n')
+	}
+	return b
+}
+
+// read a reply: integers are int64, simple and bulk strings are string
+// (a nil bulk string is nil), arrays are []interface{} and errors are
+// returned as a RedisError
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errBadReply
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, RedisError(line)
+	case ':':
+		n, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return nil, errBadReply
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < -1 {
+			return nil, errBadReply
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < -1 {
+			return nil, errBadReply
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			// errors inside arrays are values, not failures
+			v, err := readReply(r)
+			if e, ok := err.(RedisError); ok {
+				v, err = e, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			list[i] = v
+		}
+		return list, nil
+	default:
+		return nil, errBadReply
+	}
+}
+
+// the reply must be an integer
+func replyInt(v interface{}, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("puid: redis: expected an integer reply, got %T", v)
+	}
+	return n, nil
+}
+
+type redisAllocator struct {
+	client *respClient
+	key    string
+}
+
+// A RangeAllocator using INCRBY on a key in the redis server at addr
+// (host:port). It speaks the redis protocol itself, so there is no
+// client library to pull in.
+func NewRedisAllocator(addr, key string) RangeAllocator {
+	return &redisAllocator{client: newRespClient(addr, 0), key: key}
+}
+
+// implements the RangeAllocator interface
+func (a *redisAllocator) Reserve(ctx context.Context, n int64) (int64, error) {
+	end, err := replyInt(a.client.do(ctx, "INCRBY", a.key, strconv.FormatInt(n, 10)))
+	if err != nil {
+		return 0, err
+	}
+	return end - n, nil
+}
+
+// The options for a RedisCounter, all are optional
+type RedisOptions struct {
+	Timeout    time.Duration // for each command, default 100ms
+	MaxIdle    int           // connections kept open, default 4
+	Fallback   Counter       // used when redis fails, default a local AtomicCounter
+	RetryAfter time.Duration // how long to use the fallback before trying redis again, default 1s
+	OnError    func(error)   // called with each redis failure, e.g. to log it
+}
+
+// A Counter using INCR on a key in the redis server at addr (host:port),
+// so many machines share one counter sequence. It speaks the redis protocol
+// itself, so there is no client library to pull in.
+//
+// If redis fails, the counter carries on with the Fallback counter (so ids
+// are only as unique as without redis, until it comes back) and tries redis
+// again after RetryAfter. Use a BlockCounter with NewRedisAllocator to make
+// fewer calls, or to fail rather than fall back.
+type RedisCounter struct {
+	client     *respClient
+	key        string
+	timeout    time.Duration
+	fallback   Counter
+	retryAfter time.Duration
+	onError    func(error)
+	retryAt    atomic.Int64 // unix nano, while we are using the fallback
+}
+
+// Create a RedisCounter, o may be nil for the defaults
+func NewRedisCounter(addr, key string, o *RedisOptions) *RedisCounter {
+	if o == nil {
+		o = &RedisOptions{}
+	}
+	c := &RedisCounter{
+		client:     newRespClient(addr, o.MaxIdle),
+		key:        key,
+		timeout:    o.Timeout,
+		fallback:   o.Fallback,
+		retryAfter: o.RetryAfter,
+		onError:    o.OnError,
+	}
+	if c.timeout <= 0 {
+		c.timeout = 100 * time.Millisecond
+	}
+	if c.fallback == nil {
+		c.fallback = getDefaultCounter(getDefaultRandom(), false)
+	}
+	if c.retryAfter <= 0 {
+		c.retryAfter = time.Second
+	}
+	return c
+}
+
+// implements the Counter interface
+func (c *RedisCounter) Next() int64 {
+	if retryAt := c.retryAt.Load(); retryAt != 0 && time.Now().UnixNano() < retryAt {
+		return c.fallback.Next()
+	}
+	n, err := c.NextE()
+	if err != nil {
+		c.retryAt.Store(time.Now().Add(c.retryAfter).UnixNano())
+		if c.onError != nil {
+			c.onError(err)
+		}
+		return c.fallback.Next()
+	}
+	c.retryAt.Store(0)
+	return n
+}
+
+// The next value from redis, without the fallback
+func (c *RedisCounter) NextE() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	n, err := replyInt(c.client.do(ctx, "INCR", c.key))
+	if err != nil {
+		return 0, err
+	}
+	// INCR starts at 1, we start at 0
+	n = (n - 1) % MAX_COUNTER
+	if n < 0 {
+		n += MAX_COUNTER
+	}
+	return n, nil
+}
+
+// Close the idle connections
+func (c *RedisCounter) Close() error {
+	c.client.close()
+	return nil
+}
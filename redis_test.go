@@ -0,0 +1,307 @@
+package puid
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A tiny redis server that knows INCR, INCRBY, GET, SET and PING
+type fakeRedis struct {
+	ln     net.Listener
+	mtx    sync.Mutex
+	values map[string]int64
+	dials  int           // how many connections we have accepted
+	delay  time.Duration // wait this long before each reply
+	conns  []net.Conn
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{ln: ln, values: map[string]int64{}}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeRedis) addr() string {
+	return s.ln.Addr().String()
+}
+
+// stop listening and drop everyone
+func (s *fakeRedis) stop() {
+	s.ln.Close()
+	s.mtx.Lock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.mtx.Unlock()
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mtx.Lock()
+		s.dials++
+		s.conns = append(s.conns, conn)
+		s.mtx.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		v, err := readReply(r)
+		if err != nil {
+			return
+		}
+		args, _ := v.([]interface{})
+		cmd := make([]string, len(args))
+		for i, a := range args {
+			cmd[i], _ = a.(string)
+		}
+		s.mtx.Lock()
+		reply, delay := s.exec(cmd), s.delay
+		s.mtx.Unlock()
+		time.Sleep(delay)
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeRedis) exec(cmd []string) string {
+	if len(cmd) == 0 {
+		return "-ERR empty command\r\n"
+	}
+	by := int64(1)
+	switch strings.ToUpper(cmd[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "INCRBY":
+		if len(cmd) != 3 {
+			return "-ERR wrong number of arguments\r\n"
+		}
+		n, err := strconv.ParseInt(cmd[2], 10, 64)
+		if err != nil {
+			return "-ERR value is not an integer\r\n"
+		}
+		by = n
+		fallthrough
+	case "INCR":
+		if cmd[1] == "wrongtype" {
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		s.values[cmd[1]] += by
+		return ":" + strconv.FormatInt(s.values[cmd[1]], 10) + "\r\n"
+	case "SET":
+		n, err := strconv.ParseInt(cmd[2], 10, 64)
+		if err != nil {
+			return "-ERR value is not an integer\r\n"
+		}
+		s.values[cmd[1]] = n
+		return "+OK\r\n"
+	case "GET":
+		v, ok := s.values[cmd[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		b := strconv.FormatInt(v, 10)
+		return "$" + strconv.Itoa(len(b)) + "\r\n" + b + "\r\n"
+	default:
+		return "-ERR unknown command '" + cmd[0] + "'\r\n"
+	}
+}
+
+func Test_RespReplies(t *testing.T) {
+	tests := []struct {
+		in  string
+		out interface{}
+		err error
+	}{
+		{"+OK\r\n", "OK", nil},
+		{":42\r\n", int64(42), nil},
+		{"$5\r\nhello\r\n", "hello", nil},
+		{"$-1\r\n", nil, nil},
+		{"-ERR nope\r\n", nil, RedisError("ERR nope")},
+		{"?\r\n", nil, errBadReply},
+		{":x\r\n", nil, errBadReply},
+	}
+	for _, tt := range tests {
+		v, err := readReply(bufio.NewReader(strings.NewReader(tt.in)))
+		if v != tt.out || err != tt.err {
+			t.Errorf("reading %q: expected %v %v, got %v %v", tt.in, tt.out, tt.err, v, err)
+		}
+	}
+	v, err := readReply(bufio.NewReader(strings.NewReader("*3\r\n:1\r\n$1\r\na\r\n-ERR x\r\n")))
+	list, _ := v.([]interface{})
+	if err != nil || len(list) != 3 || list[0] != int64(1) || list[1] != "a" || list[2] != RedisError("ERR x") {
+		t.Errorf("unexpected array reply %#v %v", v, err)
+	}
+	if s := string(appendCommand(nil, []string{"INCRBY", "k", "10"})); s != "*3\r\n$6\r\nINCRBY\r\n$1\r\nk\r\n$2\r\n10\r\n" {
+		t.Errorf("unexpected command encoding %q", s)
+	}
+}
+
+func Test_RedisAllocator(t *testing.T) {
+	s := newFakeRedis(t)
+	a := NewRedisAllocator(s.addr(), "ids")
+	for _, e := range []int64{0, 100, 200} {
+		start, err := a.Reserve(context.Background(), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if start != e {
+			t.Errorf("expected range to start at %d, got %d", e, start)
+		}
+	}
+	c := NewBlockCounter(a, 100, time.Second)
+	if v, err := c.Next(); err != nil || v != 300 {
+		t.Errorf("unexpected value from BlockCounter %d %v", v, err)
+	}
+
+	// errors from the server are errors
+	var re RedisError
+	if _, err := NewRedisAllocator(s.addr(), "wrongtype").Reserve(context.Background(), 1); !errors.As(err, &re) {
+		t.Errorf("expected a RedisError, got %v", err)
+	}
+	s.stop()
+	if _, err := a.Reserve(context.Background(), 100); err == nil {
+		t.Error("expected an error with the server gone")
+	}
+}
+
+func Test_RedisAllocatorTimeout(t *testing.T) {
+	s := newFakeRedis(t)
+	s.delay = 200 * time.Millisecond
+	c := NewBlockCounter(NewRedisAllocator(s.addr(), "ids"), 100, 20*time.Millisecond)
+	var ne net.Error
+	if _, err := c.Next(); !errors.As(err, &ne) || !ne.Timeout() {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func Test_RedisCounter(t *testing.T) {
+	s := newFakeRedis(t)
+	c := NewRedisCounter(s.addr(), "ids", nil)
+	defer c.Close()
+	for i := int64(0); i < 100; i++ {
+		if v := c.Next(); v != i {
+			t.Fatalf("expected %d, got %d", i, v)
+		}
+	}
+	// and it wraps
+	s.mtx.Lock()
+	s.values["ids"] = MAX_COUNTER - 1
+	s.mtx.Unlock()
+	if a, b := c.Next(), c.Next(); a != MAX_COUNTER-1 || b != 0 {
+		t.Errorf("expected the counter to wrap, got %d then %d", a, b)
+	}
+	// all on one connection
+	s.mtx.Lock()
+	dials := s.dials
+	s.mtx.Unlock()
+	if dials != 1 {
+		t.Errorf("expected the connection to be reused, got %d dials", dials)
+	}
+	g := WithCounter(c)
+	if id := g.New(); id[9:9+BLOCK] != "0001" {
+		t.Errorf("unexpected counter in id %s", id)
+	}
+}
+
+func Test_RedisCounterPool(t *testing.T) {
+	s := newFakeRedis(t)
+	c := NewRedisCounter(s.addr(), "ids", &RedisOptions{MaxIdle: 2})
+	defer c.Close()
+	var wg sync.WaitGroup
+	values := make(chan int64, 800)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				v, err := c.NextE()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				values <- v
+			}
+		}()
+	}
+	wg.Wait()
+	close(values)
+	seen := map[int64]bool{}
+	for v := range values {
+		if seen[v] {
+			t.Fatalf("value %d handed out twice", v)
+		}
+		seen[v] = true
+	}
+	if len(c.client.idle) > 2 {
+		t.Errorf("expected at most 2 idle connections, got %d", len(c.client.idle))
+	}
+}
+
+func Test_RedisCounterFallback(t *testing.T) {
+	s := newFakeRedis(t)
+	var errs []error
+	c := NewRedisCounter(s.addr(), "ids", &RedisOptions{
+		Timeout:    20 * time.Millisecond,
+		Fallback:   NewAtomicCounter(1000, nil),
+		RetryAfter: 50 * time.Millisecond,
+		OnError:    func(err error) { errs = append(errs, err) },
+	})
+	defer c.Close()
+	if v := c.Next(); v != 0 {
+		t.Fatalf("expected 0 from redis, got %d", v)
+	}
+	// too slow, so we time out and fall back
+	s.mtx.Lock()
+	s.delay = 100 * time.Millisecond
+	s.mtx.Unlock()
+	if v := c.Next(); v != 1000 {
+		t.Errorf("expected the fallback value, got %d", v)
+	}
+	// and stay on the fallback without asking redis for a while
+	if v := c.Next(); v != 1001 || len(errs) != 1 {
+		t.Errorf("expected the fallback without another error, got %d %v", v, errs)
+	}
+	var ne net.Error
+	if len(errs) == 0 || !errors.As(errs[0], &ne) || !ne.Timeout() {
+		t.Errorf("expected a timeout error, got %v", errs)
+	}
+	// then back to redis when it recovers
+	s.mtx.Lock()
+	s.delay = 0
+	s.mtx.Unlock()
+	time.Sleep(60 * time.Millisecond)
+	if v := c.Next(); v < 1 || v > 2 {
+		t.Errorf("expected to be back on redis, got %d", v)
+	}
+
+	// with no server at all
+	s.stop()
+	if _, err := c.NextE(); err == nil {
+		t.Error("expected an error with the server gone")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if v := c.Next(); v != 1002 {
+		t.Errorf("expected the fallback value, got %d", v)
+	}
+}
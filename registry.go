@@ -0,0 +1,103 @@
+package puid
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The error Register wraps when a prefix is already taken
+var ErrPrefixTaken = errors.New("puid: prefix already registered")
+
+// What a prefix means
+type Entity struct {
+	Prefix      string
+	Name        string
+	Description string
+}
+
+// A Registry keeps track of which prefix is used for which entity
+// so different parts of a system don't pick the same one.
+// Prefixes may not overlap, i.e. if "u" is registered then "us" cannot be.
+// The zero value is an empty registry ready to use.
+type Registry struct {
+	mtx      sync.RWMutex
+	entities map[string]Entity
+}
+
+// Create an empty registry
+func NewRegistry() *Registry {
+	return &Registry{entities: map[string]Entity{}}
+}
+
+// Register a prefix with a name and description
+// fails if the prefix is the same as, or overlaps an existing one.
+func (r *Registry) Register(prefix, name, description string) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for p, e := range r.entities {
+		if strings.HasPrefix(p, prefix) || strings.HasPrefix(prefix, p) {
+			return fmt.Errorf("%w: %q overlaps %q (%s)", ErrPrefixTaken, prefix, p, e.Name)
+		}
+	}
+	if r.entities == nil {
+		r.entities = map[string]Entity{}
+	}
+	r.entities[prefix] = Entity{Prefix: prefix, Name: name, Description: description}
+	return nil
+}
+
+// Like Register but panics on error, for use in package level vars and init()
+func (r *Registry) MustRegister(prefix, name, description string) {
+	if err := r.Register(prefix, name, description); err != nil {
+		panic(err)
+	}
+}
+
+// Find the entity for the prefix of a puid
+func (r *Registry) Lookup(id string) (Entity, bool) {
+	v, err := Parse(id)
+	if err != nil {
+		return Entity{}, false
+	}
+	r.mtx.RLock()
+	e, ok := r.entities[v.Prefix]
+	r.mtx.RUnlock()
+	return e, ok
+}
+
+// All the registered entities, ordered by prefix
+func (r *Registry) Entities() []Entity {
+	r.mtx.RLock()
+	list := make([]Entity, 0, len(r.entities))
+	for _, e := range r.entities {
+		list = append(list, e)
+	}
+	r.mtx.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Prefix < list[j].Prefix })
+	return list
+}
+
+var defaultRegistry = NewRegistry()
+
+// Access the package level registry
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Register a prefix in the default registry
+func Register(prefix, name, description string) error {
+	return defaultRegistry.Register(prefix, name, description)
+}
+
+// Register a prefix in the default registry, panics on error
+func MustRegister(prefix, name, description string) {
+	defaultRegistry.MustRegister(prefix, name, description)
+}
+
+// Find the entity for a puid in the default registry
+func Lookup(id string) (Entity, bool) {
+	return defaultRegistry.Lookup(id)
+}
@@ -0,0 +1,70 @@
+package puid
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_RegistryOverlaps(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register("u", "user", "a person"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("o", "order", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"u", "us", "o:", ""} {
+		if err := r.Register(p, "dupe", ""); !errors.Is(err, ErrPrefixTaken) {
+			t.Errorf("expected ErrPrefixTaken registering %q, got %v", p, err)
+		}
+	}
+	if err := r.Register("s", "session", ""); err != nil {
+		t.Errorf("non-overlapping prefix should register: %s", err)
+	}
+	list := r.Entities()
+	if len(list) != 3 || list[0].Name != "order" || list[1].Name != "session" || list[2].Name != "user" {
+		t.Errorf("unexpected entity list %+v", list)
+	}
+}
+
+func Test_RegistryLookup(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("u", "user", "a person")
+	r.MustRegister("ord:", "order", "")
+	e, ok := r.Lookup(WithPrefix("u").New())
+	if !ok || e.Name != "user" || e.Description != "a person" {
+		t.Errorf("unexpected lookup result %+v", e)
+	}
+	if e, ok := r.Lookup(WithPrefix("ord:").New()); !ok || e.Name != "order" {
+		t.Errorf("unexpected lookup result %+v", e)
+	}
+	for _, id := range []string{WithPrefix("us").New(), New(), "u-nope"} {
+		if e, ok := r.Lookup(id); ok {
+			t.Errorf("unexpected lookup of %q: %+v", id, e)
+		}
+	}
+}
+
+func Test_MustRegisterPanics(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("u", "user", "")
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("we should have panic'd on a duplicate prefix")
+		}
+	}()
+	r.MustRegister("u", "user", "")
+}
+
+func Test_RegistryZeroValue(t *testing.T) {
+	var r Registry
+	if _, ok := r.Lookup(NewID().String()); ok {
+		t.Error("empty registry should not find anything")
+	}
+	if err := r.Register("p", "thing", ""); err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := r.Lookup(New()); !ok || e.Name != "thing" {
+		t.Errorf("unexpected lookup from a zero value registry %+v %v", e, ok)
+	}
+}
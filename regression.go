@@ -0,0 +1,114 @@
+package puid
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// What a generator does when its clock goes back past the last
+// timestamp it issued (NTP corrections, VM migrations...)
+type RegressionPolicy int
+
+const (
+	// carry on with the clock's time, this is how it always worked
+	RegressionIgnore RegressionPolicy = iota
+	// keep using the last timestamp until the clock catches up
+	RegressionLogical
+	// wait until the clock catches up
+	RegressionBlock
+	// fail, NewE and AppendBytesE return ErrClockRegression (New panics)
+	RegressionError
+)
+
+// The error from NewE and AppendBytesE with RegressionError when the clock
+// has gone back
+var ErrClockRegression = errors.New("puid: clock moved backwards")
+
+// keeps the last timestamp issued, shared by clones of a generator
+type regressionTracker struct {
+	policy RegressionPolicy
+	hook   func(last, now time.Time)
+	last   atomic.Int64
+	behind atomic.Bool // so we only call the hook once each time
+}
+
+func newRegressionTracker(p RegressionPolicy, hook func(last, now time.Time)) *regressionTracker {
+	if p == RegressionIgnore && hook == nil {
+		// nothing to do, so we don't pay for tracking
+		return nil
+	}
+	return &regressionTracker{policy: p, hook: hook}
+}
+
+// the timestamp to use for the next id, after applying the policy
+func (r *regressionTracker) check(g *Generator, now int64) (int64, error) {
+	for {
+		last := r.last.Load()
+		if now >= last {
+			if now == last || r.last.CompareAndSwap(last, now) {
+				r.behind.Store(false)
+				return now, nil
+			}
+			continue
+		}
+		if r.hook != nil && r.behind.CompareAndSwap(false, true) {
+			r.hook(time.UnixMilli(last), time.UnixMilli(now))
+		}
+		switch r.policy {
+		case RegressionLogical:
+			return last, nil
+		case RegressionBlock:
+			time.Sleep(time.Duration(last-now) * time.Millisecond)
+			now = g.hammertime()
+		case RegressionError:
+			return 0, ErrClockRegression
+		default:
+			return now, nil
+		}
+	}
+}
+
+// the timestamp for the next id
+func (g *Generator) timestamp() (int64, error) {
+	now := g.hammertime()
+	if g.regression == nil {
+		return now, nil
+	}
+	return g.regression.check(g, now)
+}
+
+// Return a new generator like this one, but with the given policy for when
+// the clock goes backwards. The new generator tracks timestamps from scratch.
+func (g *Generator) WithRegressionPolicy(p RegressionPolicy) *Generator {
+	n := g.dup()
+	var hook func(last, now time.Time)
+	if g.regression != nil {
+		hook = g.regression.hook
+	}
+	n.regression = newRegressionTracker(p, hook)
+	return n
+}
+
+// Returns the default generator but with the given clock regression policy
+func WithRegressionPolicy(p RegressionPolicy) *Generator {
+	return defaultGenerator.WithRegressionPolicy(p)
+}
+
+// Return a new generator like this one, but calling fn when the clock goes
+// back before the last timestamp issued, once each time it happens.
+// The new generator tracks timestamps from scratch.
+func (g *Generator) WithRegressionHook(fn func(last, now time.Time)) *Generator {
+	n := g.dup()
+	p := RegressionIgnore
+	if g.regression != nil {
+		p = g.regression.policy
+	}
+	n.regression = newRegressionTracker(p, fn)
+	return n
+}
+
+// Returns the default generator but with the given clock regression hook
+func WithRegressionHook(fn func(last, now time.Time)) *Generator {
+	return defaultGenerator.WithRegressionHook(fn)
+}
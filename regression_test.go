@@ -0,0 +1,115 @@
+package puid
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_RegressionIgnore(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	var hooked []time.Time
+	g := WithClock(clock).WithRegressionHook(func(last, now time.Time) {
+		hooked = append(hooked, last, now)
+	})
+	a, _ := g.Parse(g.New())
+	clock.Advance(-time.Second)
+	b, _ := g.Parse(g.New())
+	g.New()
+	if !b.Timestamp.Equal(a.Timestamp.Add(-time.Second)) {
+		t.Errorf("expected the clock's time to be used, got %s", b.Timestamp)
+	}
+	if len(hooked) != 2 || !hooked[0].Equal(a.Timestamp) || !hooked[1].Equal(b.Timestamp) {
+		t.Errorf("expected the hook to be called once with the times, got %v", hooked)
+	}
+	// catching up and going back again is another regression
+	clock.Advance(2 * time.Second)
+	g.New()
+	clock.Advance(-time.Second)
+	g.New()
+	if len(hooked) != 4 {
+		t.Errorf("expected the hook to be called again, got %v", hooked)
+	}
+}
+
+func Test_RegressionLogical(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	g := NewGenerator(&Options{Clock: clock, Regression: RegressionLogical})
+	a, _ := g.Parse(g.New())
+	clock.Advance(-time.Second)
+	b, _ := g.Parse(g.New())
+	if !b.Timestamp.Equal(a.Timestamp) {
+		t.Errorf("expected the last timestamp to be used, got %s then %s", a.Timestamp, b.Timestamp)
+	}
+	clock.Advance(time.Second + time.Millisecond)
+	c, _ := g.Parse(g.New())
+	if !c.Timestamp.Equal(a.Timestamp.Add(time.Millisecond)) {
+		t.Errorf("expected the clock's time once it caught up, got %s", c.Timestamp)
+	}
+}
+
+func Test_RegressionBlock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	g := WithClock(clock).WithRegressionPolicy(RegressionBlock)
+	a, _ := g.Parse(g.New())
+	clock.Advance(-5 * time.Millisecond)
+	done := make(chan string)
+	go func() { done <- g.New() }()
+	select {
+	case s := <-done:
+		t.Fatalf("expected to block until the clock caught up, got %s", s)
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(5 * time.Millisecond)
+	b, _ := g.Parse(<-done)
+	if !b.Timestamp.Equal(a.Timestamp) {
+		t.Errorf("expected the caught up time, got %s", b.Timestamp)
+	}
+}
+
+func Test_RegressionError(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	hooked := 0
+	g := WithClock(clock).
+		WithRegressionPolicy(RegressionError).
+		WithRegressionHook(func(last, now time.Time) { hooked++ })
+	if _, err := g.NewE(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(-time.Millisecond)
+	if _, err := g.NewE(); !errors.Is(err, ErrClockRegression) {
+		t.Errorf("expected ErrClockRegression, got %v", err)
+	}
+	func() {
+		defer func() {
+			if err := recover(); err != ErrClockRegression {
+				t.Errorf("expected New to panic with ErrClockRegression, got %v", err)
+			}
+		}()
+		g.New()
+	}()
+	if hooked != 1 {
+		t.Errorf("expected the hook to be called once, got %d", hooked)
+	}
+	clock.Advance(time.Millisecond)
+	if _, err := g.NewE(); err != nil {
+		t.Errorf("expected no error once the clock caught up, got %v", err)
+	}
+}
+
+func Test_RegressionNewClock(t *testing.T) {
+	g := WithClock(NewFakeClock(time.Unix(1500000000, 0))).WithRegressionPolicy(RegressionError)
+	if _, err := g.NewE(); err != nil {
+		t.Fatal(err)
+	}
+	// an earlier clock is not a regression, it is another timeline
+	earlier := NewFakeClock(time.Unix(1400000000, 0))
+	if _, err := g.WithClock(earlier).NewE(); err != nil {
+		t.Errorf("expected no error with a new clock, got %v", err)
+	}
+	// and a later one doesn't hold the original back
+	g.WithClock(NewFakeClock(time.Unix(1600000000, 0))).New()
+	if _, err := g.NewE(); err != nil {
+		t.Errorf("expected no error after a clone with a later clock, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package puid
+
+import "sync"
+
+// The last (timestamp, counter) pair issued by a sortable generator
+type sequence struct {
+	mtx     sync.Mutex
+	time    int64
+	counter int64
+}
+
+// we start before any real timestamp so the first pair is always accepted
+func newSequence() *sequence {
+	return &sequence{time: -1}
+}
+
+// Returns a (timestamp, counter) pair strictly greater than the last one.
+// While the clock stays on (or goes back before) the last timestamp we keep
+// using that timestamp as long as the counter increases, when it doesn't
+// (i.e. it rolled over) we borrow the next millisecond.
+// We take the counter while holding the lock, so values come to us in the
+// order they were handed out and only a real rollover moves the time on.
+func (g *Generator) nextSortable(now int64) (int64, int64, error) {
+	s := g.seq
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	c, err := g.nextCounter()
+	if err != nil {
+		return 0, 0, err
+	}
+	switch {
+	case now > s.time:
+		s.time = now
+	case c <= s.counter:
+		s.time++
+	}
+	s.counter = c
+	return s.time, c, nil
+}
+
+// pick the sequence for the way the generator keeps its ids in order:
+// monotonic generators share one with the generator they came from,
+// sortable ones get a fresh one and the rest need none
+func (g *Generator) setSequence() {
+	switch {
+	case g.monotonic:
+		g.seq = g.monoSeq
+	case g.sortable:
+		g.seq = newSequence()
+	default:
+		g.seq = nil
+	}
+}
+
+// Return a new generator like this one, but with sortable mode on or off.
+// In sortable mode the timestamp is always padded to 2 blocks and the
+// (timestamp, counter) pair always increases, so ids from one generator
+// sort as strings in the order they were created.
+// The 2 block timestamp runs out in 2059, after that the ids get longer
+// and you should not rely on sorting ids from either side of it.
+func (g *Generator) WithSortable(on bool) *Generator {
+	n := g.dup()
+	n.sortable = on
+	n.setSequence()
+	return n
+}
+
+// Returns the default generator but in sortable mode
+func Sortable() *Generator {
+	return defaultGenerator.WithSortable(true)
+}
@@ -0,0 +1,94 @@
+package puid
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_SortableOrder(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+
+	// a counter just short of rolling over, so we hit that too
+	g := NewGenerator(&Options{
+		Sortable: true,
+		Clock:    clock,
+		Counter:  &counterMutex{value: MAX_COUNTER - 10},
+	})
+	ids := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		switch i {
+		case 30, 31, 60:
+			clock.Advance(time.Millisecond) // millisecond boundaries
+		case 80:
+			clock.Advance(-5 * time.Millisecond) // and the clock stepping back
+		}
+		ids = append(ids, g.New())
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i-1] >= ids[i] {
+			t.Errorf("id %d `%s` does not sort after id %d `%s`", i, ids[i], i-1, ids[i-1])
+		}
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Error("ids are not in generation order")
+	}
+}
+
+func Test_SortableRolloverBorrowsMillisecond(t *testing.T) {
+	g := WithCounter(&counterMutex{value: MAX_COUNTER - 1}).
+		WithClock(NewFakeClock(time.Unix(1500000000, 0))).
+		WithSortable(true)
+	a, _ := g.Parse(g.New())
+	b, _ := g.Parse(g.New())
+	if a.Counter != MAX_COUNTER-1 || b.Counter != 0 {
+		t.Fatalf("expected the counter to roll over, got %d then %d", a.Counter, b.Counter)
+	}
+	if b.Timestamp.Sub(a.Timestamp) != time.Millisecond {
+		t.Errorf("expected rollover to move to the next millisecond, got %s then %s", a.Timestamp, b.Timestamp)
+	}
+}
+
+func Test_SortablePadsTimestamp(t *testing.T) {
+	g := &Generator{
+		prefix:      []byte{'x'},
+		random:      badRandom(27),
+		fingerprint: []byte("ffff"),
+		counter:     dumbCounter(0),
+		clock:       NewFakeClock(time.Unix(0, 0)),
+	}
+	if id := g.New(); id != "x00000ffffrrrrrrrr" {
+		t.Errorf("did not expect the unpadded timestamp in: `%s`", id)
+	}
+	if id := g.WithSortable(true).New(); id != "x000000000000ffffrrrrrrrr" {
+		t.Errorf("expected the timestamp padded to 2 blocks in: `%s`", id)
+	}
+}
+
+func Test_SortableParallelKeepsToClock(t *testing.T) {
+	g := Sortable()
+	var wg sync.WaitGroup
+	last := make([]string, 8)
+	for i := range last {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20000; j++ {
+				last[i] = g.New()
+			}
+		}(i)
+	}
+	wg.Wait()
+	now := time.Now()
+	for _, s := range last {
+		id, err := g.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// only a counter rollover may move us ahead, and that only once here
+		if id.Timestamp.After(now.Add(time.Millisecond)) {
+			t.Errorf("timestamp %s has run ahead of the clock %s", id.Timestamp, now)
+		}
+	}
+}
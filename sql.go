@@ -0,0 +1,267 @@
+package puid
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// How the SQL placeholders look for your database driver
+type Placeholders int
+
+const (
+	// `?`, as used by MySQL and SQLite
+	QuestionPlaceholders Placeholders = iota
+	// `$1`, as used by Postgres
+	DollarPlaceholders
+)
+
+// rewrite a query written with `?` placeholders for the driver
+func (p Placeholders) rewrite(query string) string {
+	if p != DollarPlaceholders {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		} else {
+			b.WriteByte(query[i])
+		}
+	}
+	return b.String()
+}
+
+// the row for an allocator doesn't exist yet
+var errNoCounterRow = errors.New("puid: counter row does not exist")
+
+type sqlAllocator struct {
+	db     *sql.DB
+	name   string
+	update string
+	query  string
+	insert string
+}
+
+// A RangeAllocator keeping counters in a database table, which must exist
+// with (at least) these columns:
+//
+//	CREATE TABLE puid_counters (name VARCHAR(64) PRIMARY KEY, value BIGINT NOT NULL)
+//
+// Each allocator uses the row with its name, which is created on first use.
+// Only portable SQL is used, so any driver will do. Note the table name is
+// put in the queries as is.
+func NewSQLAllocator(db *sql.DB, table, name string, p Placeholders) RangeAllocator {
+	return &sqlAllocator{
+		db:     db,
+		name:   name,
+		update: p.rewrite("UPDATE " + table + " SET value = value + ? WHERE name = ?"),
+		query:  p.rewrite("SELECT value FROM " + table + " WHERE name = ?"),
+		insert: p.rewrite("INSERT INTO " + table + " (name, value) VALUES (?, ?)"),
+	}
+}
+
+// implements the RangeAllocator interface
+func (a *sqlAllocator) Reserve(ctx context.Context, n int64) (int64, error) {
+	start, err := a.reserve(ctx, n)
+	if err != errNoCounterRow {
+		return start, err
+	}
+	// first use, so create the row with our range already taken. If someone
+	// beat us to it the insert fails, and the update will work this time.
+	if _, err := a.db.ExecContext(ctx, a.insert, a.name, n); err == nil {
+		return 0, nil
+	}
+	return a.reserve(ctx, n)
+}
+
+// move the value on in a transaction, so we know where our range started
+func (a *sqlAllocator) reserve(ctx context.Context, n int64) (int64, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, a.update, n, a.name)
+	if err != nil {
+		return 0, err
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if rows == 0 {
+		return 0, errNoCounterRow
+	}
+	var end int64
+	if err := tx.QueryRowContext(ctx, a.query, a.name).Scan(&end); err != nil {
+		return 0, err
+	}
+	return end - n, tx.Commit()
+}
+
+// The options for the SQL counter and fingerprint leases, all are optional
+type SQLOptions struct {
+	Table        string        // default "puid_counters" or "puid_fingerprints"
+	Placeholders Placeholders  // default `?`
+	Block        int64         // values a SQLCounter reserves at a time, default 1000
+	Timeout      time.Duration // for each reservation, default 1s
+}
+
+// A CounterE reserving blocks of values from a row in a database table,
+// see NewSQLAllocator for the table it needs.
+type SQLCounter struct {
+	*BlockCounter
+}
+
+// Create a SQLCounter using the row with the given name, o may be nil
+// for the defaults
+func NewSQLCounter(db *sql.DB, name string, o *SQLOptions) *SQLCounter {
+	if o == nil {
+		o = &SQLOptions{}
+	}
+	table, block, timeout := o.Table, o.Block, o.Timeout
+	if table == "" {
+		table = "puid_counters"
+	}
+	if block < 1 {
+		block = 1000
+	}
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	return &SQLCounter{NewBlockCounter(NewSQLAllocator(db, table, name, o.Placeholders), block, timeout)}
+}
+
+// how many fingerprints we try before giving up
+const maxLeaseAttempts = 64
+
+// The error when no free fingerprint could be found
+var ErrNoFingerprint = errors.New("puid: no free fingerprint to lease")
+
+// Leases fingerprints from a database table, so every live instance
+// sharing the database has a different one. The table must exist
+// with (at least) these columns:
+//
+//	CREATE TABLE puid_fingerprints (fingerprint CHAR(4) PRIMARY KEY, owner VARCHAR(64) NOT NULL, expires BIGINT NOT NULL)
+//
+// A lease lasts for the ttl unless it is renewed, after that another
+// instance may take the fingerprint. Expiry times are written by the
+// instances themselves (in unix milliseconds), so their clocks should
+// agree to well within the ttl.
+type SQLFingerprintLeaser struct {
+	db     *sql.DB
+	ttl    time.Duration
+	take   string
+	insert string
+	renew  string
+	delete string
+}
+
+// Create a SQLFingerprintLeaser, o may be nil for the defaults
+// (only Table and Placeholders are used)
+func NewSQLFingerprintLeaser(db *sql.DB, ttl time.Duration, o *SQLOptions) *SQLFingerprintLeaser {
+	if o == nil {
+		o = &SQLOptions{}
+	}
+	table, p := o.Table, o.Placeholders
+	if table == "" {
+		table = "puid_fingerprints"
+	}
+	return &SQLFingerprintLeaser{
+		db:     db,
+		ttl:    ttl,
+		take:   p.rewrite("UPDATE " + table + " SET owner = ?, expires = ? WHERE fingerprint = ? AND expires < ?"),
+		insert: p.rewrite("INSERT INTO " + table + " (fingerprint, owner, expires) VALUES (?, ?, ?)"),
+		renew:  p.rewrite("UPDATE " + table + " SET expires = ? WHERE fingerprint = ? AND owner = ?"),
+		delete: p.rewrite("DELETE FROM " + table + " WHERE fingerprint = ? AND owner = ?"),
+	}
+}
+
+// A fingerprint leased from a database table
+type SQLFingerprintLease struct {
+	leaser      *SQLFingerprintLeaser
+	fingerprint string
+	owner       string
+	expires     atomic.Int64 // unix milliseconds
+}
+
+// Find a free (or expired) fingerprint and lease it, the lease is a
+// *SQLFingerprintLease. We start from a random one, so instances don't
+// all fight over the same.
+func (l *SQLFingerprintLeaser) Acquire(ctx context.Context) (FingerprintLease, error) {
+	// the owner just needs to be unique, and we know how to do that
+	owner := New()
+	n := randomCounterStart(getDefaultRandom())
+	for i := 0; i < maxLeaseAttempts; i++ {
+		fp := string(appendPaddedInt(nil, (n+int64(i))%MAX_COUNTER, BLOCK))
+		now := time.Now()
+		expires := now.Add(l.ttl).UnixMilli()
+		res, err := l.db.ExecContext(ctx, l.take, owner, expires, fp, now.UnixMilli())
+		if err != nil {
+			return nil, err
+		}
+		taken, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if taken == 0 {
+			// nobody has had it, or someone has it now. If it's the
+			// latter the insert fails and we move on to the next one
+			if _, err := l.db.ExecContext(ctx, l.insert, fp, owner, expires); err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				continue
+			}
+		}
+		lease := &SQLFingerprintLease{leaser: l, fingerprint: fp, owner: owner}
+		lease.expires.Store(expires)
+		return lease, nil
+	}
+	return nil, ErrNoFingerprint
+}
+
+// The leased fingerprint
+func (l *SQLFingerprintLease) Fingerprint() []byte {
+	return []byte(l.fingerprint)
+}
+
+// When the lease runs out, unless it is renewed
+func (l *SQLFingerprintLease) Expires() time.Time {
+	return time.UnixMilli(l.expires.Load())
+}
+
+// Extend the lease by the ttl, fails with ErrLeaseLost if someone
+// else has taken the fingerprint
+func (l *SQLFingerprintLease) Renew(ctx context.Context) error {
+	expires := time.Now().Add(l.leaser.ttl).UnixMilli()
+	res, err := l.leaser.db.ExecContext(ctx, l.leaser.renew, expires, l.fingerprint, l.owner)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrLeaseLost
+	}
+	l.expires.Store(expires)
+	return nil
+}
+
+// Give the fingerprint back
+func (l *SQLFingerprintLease) Release(ctx context.Context) error {
+	_, err := l.leaser.db.ExecContext(ctx, l.leaser.delete, l.fingerprint, l.owner)
+	return err
+}
+
+// Renew the lease every interval until ctx is done, then release it,
+// see KeepLease
+func (l *SQLFingerprintLease) Keep(ctx context.Context, interval time.Duration, lost func(error)) {
+	go KeepLease(ctx, l, interval, lost)
+}
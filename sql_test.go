@@ -0,0 +1,428 @@
+package puid
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//
+// A fake database/sql driver, which only knows the statements we
+// register handlers for. Transactions hold a lock on the whole database
+// so they are serializable, but changes are not rolled back.
+//
+
+type fakeResult struct {
+	affected int64
+	columns  []string
+	rows     [][]driver.Value
+}
+
+type fakeHandler struct {
+	re *regexp.Regexp
+	fn func(db *fakeDB, m []string, args []driver.Value) (fakeResult, error)
+}
+
+type fakeDB struct {
+	mtx      sync.Mutex
+	handlers []fakeHandler
+	fail     error // if set every statement fails with it
+	counters map[string]int64
+	leases   map[string]fakeLease // fingerprint => lease
+	others   fakeLease            // if set, the lease for every fingerprint not in leases
+}
+
+type fakeLease struct {
+	owner   string
+	expires int64
+}
+
+var (
+	fakeDBs      sync.Map // dsn => *fakeDB
+	registerFake sync.Once
+	dollarArgs   = regexp.MustCompile(`\$\d+`)
+)
+
+// open a fresh fake database, with handlers for the counter statements
+func openFakeDB(t *testing.T) (*sql.DB, *fakeDB) {
+	registerFake.Do(func() { sql.Register("puidfake", fakeDriver{}) })
+	fdb := &fakeDB{counters: map[string]int64{}, leases: map[string]fakeLease{}}
+	fdb.handle(`^UPDATE (\w+) SET value = value \+ \? WHERE name = \?$`, func(db *fakeDB, m []string, args []driver.Value) (fakeResult, error) {
+		name := args[1].(string)
+		if _, ok := db.counters[name]; !ok {
+			return fakeResult{}, nil
+		}
+		db.counters[name] += args[0].(int64)
+		return fakeResult{affected: 1}, nil
+	})
+	fdb.handle(`^SELECT value FROM (\w+) WHERE name = \?$`, func(db *fakeDB, m []string, args []driver.Value) (fakeResult, error) {
+		r := fakeResult{columns: []string{"value"}}
+		if v, ok := db.counters[args[0].(string)]; ok {
+			r.rows = append(r.rows, []driver.Value{v})
+		}
+		return r, nil
+	})
+	fdb.handle(`^INSERT INTO (\w+) \(name, value\) VALUES \(\?, \?\)$`, func(db *fakeDB, m []string, args []driver.Value) (fakeResult, error) {
+		name := args[0].(string)
+		if _, ok := db.counters[name]; ok {
+			return fakeResult{}, errors.New("duplicate key")
+		}
+		db.counters[name] = args[1].(int64)
+		return fakeResult{affected: 1}, nil
+	})
+	fdb.handle(`^UPDATE (\w+) SET owner = \?, expires = \? WHERE fingerprint = \? AND expires < \?$`, func(db *fakeDB, m []string, args []driver.Value) (fakeResult, error) {
+		fp := args[2].(string)
+		if l, ok := db.lease(fp); !ok || l.expires >= args[3].(int64) {
+			return fakeResult{}, nil
+		}
+		db.leases[fp] = fakeLease{owner: args[0].(string), expires: args[1].(int64)}
+		return fakeResult{affected: 1}, nil
+	})
+	fdb.handle(`^INSERT INTO (\w+) \(fingerprint, owner, expires\) VALUES \(\?, \?, \?\)$`, func(db *fakeDB, m []string, args []driver.Value) (fakeResult, error) {
+		fp := args[0].(string)
+		if _, ok := db.lease(fp); ok {
+			return fakeResult{}, errors.New("duplicate key")
+		}
+		db.leases[fp] = fakeLease{owner: args[1].(string), expires: args[2].(int64)}
+		return fakeResult{affected: 1}, nil
+	})
+	fdb.handle(`^UPDATE (\w+) SET expires = \? WHERE fingerprint = \? AND owner = \?$`, func(db *fakeDB, m []string, args []driver.Value) (fakeResult, error) {
+		fp := args[1].(string)
+		if l, ok := db.leases[fp]; !ok || l.owner != args[2].(string) {
+			return fakeResult{}, nil
+		}
+		db.leases[fp] = fakeLease{owner: args[2].(string), expires: args[0].(int64)}
+		return fakeResult{affected: 1}, nil
+	})
+	fdb.handle(`^DELETE FROM (\w+) WHERE fingerprint = \? AND owner = \?$`, func(db *fakeDB, m []string, args []driver.Value) (fakeResult, error) {
+		fp := args[0].(string)
+		if l, ok := db.leases[fp]; !ok || l.owner != args[1].(string) {
+			return fakeResult{}, nil
+		}
+		delete(db.leases, fp)
+		return fakeResult{affected: 1}, nil
+	})
+	dsn := t.Name()
+	fakeDBs.Store(dsn, fdb)
+	t.Cleanup(func() { fakeDBs.Delete(dsn) })
+	db, err := sql.Open("puidfake", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, fdb
+}
+
+func (db *fakeDB) lease(fp string) (fakeLease, bool) {
+	if l, ok := db.leases[fp]; ok {
+		return l, true
+	}
+	return db.others, db.others.owner != ""
+}
+
+func (db *fakeDB) handle(pattern string, fn func(db *fakeDB, m []string, args []driver.Value) (fakeResult, error)) {
+	db.handlers = append(db.handlers, fakeHandler{re: regexp.MustCompile(pattern), fn: fn})
+}
+
+// run a statement, the caller must hold the lock
+func (db *fakeDB) run(query string, args []driver.Value) (fakeResult, error) {
+	if db.fail != nil {
+		return fakeResult{}, db.fail
+	}
+	query = dollarArgs.ReplaceAllString(strings.TrimSpace(query), "?")
+	for _, h := range db.handlers {
+		if m := h.re.FindStringSubmatch(query); m != nil {
+			return h.fn(db, m, args)
+		}
+	}
+	return fakeResult{}, errors.New("fake driver does not understand: " + query)
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	db, ok := fakeDBs.Load(dsn)
+	if !ok {
+		return nil, errors.New("no fake database " + dsn)
+	}
+	return &fakeConn{db: db.(*fakeDB)}, nil
+}
+
+type fakeConn struct {
+	db   *fakeDB
+	inTx bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.mtx.Lock()
+	c.inTx = true
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.inTx = false
+	c.db.mtx.Unlock()
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	return c.Commit()
+}
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) run(args []driver.Value) (fakeResult, error) {
+	if !s.c.inTx {
+		s.c.db.mtx.Lock()
+		defer s.c.db.mtx.Unlock()
+	}
+	return s.c.db.run(s.query, args)
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	r, err := s.run(args)
+	return driver.RowsAffected(r.affected), err
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	r, err := s.run(args)
+	return &fakeRows{r: r}, err
+}
+
+type fakeRows struct {
+	r fakeResult
+	i int
+}
+
+func (r *fakeRows) Columns() []string { return r.r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i == len(r.r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.r.rows[r.i])
+	r.i++
+	return nil
+}
+
+//
+// Tests
+//
+
+func Test_PlaceholdersRewrite(t *testing.T) {
+	q := "UPDATE t SET a = ? WHERE b = ? AND c = ?"
+	if QuestionPlaceholders.rewrite(q) != q {
+		t.Error("question placeholders should be left alone")
+	}
+	if s := DollarPlaceholders.rewrite(q); s != "UPDATE t SET a = $1 WHERE b = $2 AND c = $3" {
+		t.Errorf("unexpected dollar placeholders: %s", s)
+	}
+}
+
+func Test_SQLAllocator(t *testing.T) {
+	db, fdb := openFakeDB(t)
+	for _, p := range []Placeholders{QuestionPlaceholders, DollarPlaceholders} {
+		name := "ids" + strconv.Itoa(int(p))
+		a := NewSQLAllocator(db, "puid_counters", name, p)
+		for _, e := range []int64{0, 10, 20} {
+			start, err := a.Reserve(context.Background(), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if start != e {
+				t.Errorf("expected range to start at %d, got %d", e, start)
+			}
+		}
+		if fdb.counters[name] != 30 {
+			t.Errorf("unexpected value in table %d", fdb.counters[name])
+		}
+	}
+	fdb.fail = errors.New("db down")
+	if _, err := NewSQLAllocator(db, "puid_counters", "ids0", QuestionPlaceholders).Reserve(context.Background(), 10); err == nil {
+		t.Error("expected an error from a failing database")
+	}
+}
+
+func Test_SQLAllocatorConcurrent(t *testing.T) {
+	db, _ := openFakeDB(t)
+	c := NewBlockCounter(NewSQLAllocator(db, "puid_counters", "ids", QuestionPlaceholders), 7, 0)
+	other := NewBlockCounter(NewSQLAllocator(db, "puid_counters", "ids", QuestionPlaceholders), 5, 0)
+	seen := map[int64]bool{}
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	for _, ctr := range []*BlockCounter{c, other, c, other} {
+		wg.Add(1)
+		go func(ctr *BlockCounter) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				v, err := ctr.Next()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mtx.Lock()
+				if seen[v] {
+					t.Errorf("value %d handed out twice", v)
+				}
+				seen[v] = true
+				mtx.Unlock()
+			}
+		}(ctr)
+	}
+	wg.Wait()
+}
+
+func Test_SQLCounter(t *testing.T) {
+	db, fdb := openFakeDB(t)
+	c := NewSQLCounter(db, "ids", &SQLOptions{Placeholders: DollarPlaceholders, Block: 3})
+	for e := int64(0); e < 7; e++ {
+		v, err := c.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != e {
+			t.Errorf("expected %d, got %d", e, v)
+		}
+	}
+	if fdb.counters["ids"] != 9 {
+		t.Errorf("unexpected value in table %d", fdb.counters["ids"])
+	}
+	// it fits in a generator
+	if s, err := WithCounterE(NewSQLCounter(db, "ids", nil)).NewE(); err != nil || !IsValid(s) {
+		t.Errorf("unexpected id `%s` with error %v", s, err)
+	}
+}
+
+func Test_SQLFingerprintLease(t *testing.T) {
+	db, fdb := openFakeDB(t)
+	l := NewSQLFingerprintLeaser(db, time.Minute, nil)
+	a, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Fingerprint()) != BLOCK || !isAllBase36(a.Fingerprint()) {
+		t.Errorf("unexpected fingerprint `%s`", a.Fingerprint())
+	}
+	if string(a.Fingerprint()) == string(b.Fingerprint()) {
+		t.Errorf("two leases with the same fingerprint `%s`", a.Fingerprint())
+	}
+	if len(fdb.leases) != 2 {
+		t.Errorf("expected 2 leases in the table, got %d", len(fdb.leases))
+	}
+	before := a.Expires()
+	time.Sleep(2 * time.Millisecond)
+	if err := a.Renew(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Expires().After(before) {
+		t.Error("renewing should extend the lease")
+	}
+	if err := b.Release(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fdb.leases[string(b.Fingerprint())]; ok || len(fdb.leases) != 1 {
+		t.Error("released lease is still in the table")
+	}
+}
+
+func Test_SQLFingerprintLeaseExpiry(t *testing.T) {
+	db, fdb := openFakeDB(t)
+	// every fingerprint has an expired lease
+	expired := time.Now().Add(-time.Second).UnixMilli()
+	fdb.others = fakeLease{owner: "gone", expires: expired}
+	l := NewSQLFingerprintLeaser(db, time.Minute, &SQLOptions{Placeholders: DollarPlaceholders})
+	a, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fdb.leases[string(a.Fingerprint())].owner == "gone" {
+		t.Error("expired lease was not taken over")
+	}
+	// someone else takes over our lease
+	fdb.leases[string(a.Fingerprint())] = fakeLease{owner: "thief", expires: expired}
+	if err := a.Renew(context.Background()); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost, got %v", err)
+	}
+	// releasing must not delete the thief's lease
+	a.Release(context.Background())
+	if fdb.leases[string(a.Fingerprint())].owner != "thief" {
+		t.Error("released a lease we did not own")
+	}
+	// nothing free
+	delete(fdb.leases, string(a.Fingerprint()))
+	fdb.others = fakeLease{owner: "busy", expires: time.Now().Add(time.Hour).UnixMilli()}
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrNoFingerprint) {
+		t.Errorf("expected ErrNoFingerprint, got %v", err)
+	}
+}
+
+func Test_SQLFingerprintLeaseKeep(t *testing.T) {
+	db, fdb := openFakeDB(t)
+	a, err := NewSQLFingerprintLeaser(db, time.Minute, nil).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := string(a.Fingerprint())
+	lost := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a.(*SQLFingerprintLease).Keep(ctx, time.Millisecond, func(err error) { lost <- err })
+	fdb.mtx.Lock()
+	fdb.leases[fp] = fakeLease{owner: "thief", expires: time.Now().Add(time.Hour).UnixMilli()}
+	fdb.mtx.Unlock()
+	select {
+	case err := <-lost:
+		if !errors.Is(err, ErrLeaseLost) {
+			t.Errorf("expected ErrLeaseLost, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lost lease was not reported")
+	}
+
+	// a kept lease is released when the context is done
+	b, err := NewSQLFingerprintLeaser(db, time.Minute, nil).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	go KeepLease(ctx, b, time.Millisecond, nil)
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fdb.mtx.Lock()
+		_, ok := fdb.leases[string(b.Fingerprint())]
+		fdb.mtx.Unlock()
+		if !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("lease was not released")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
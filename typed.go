@@ -0,0 +1,133 @@
+package puid
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// A Prefixer ties a type to a puid prefix, so the compiler can tell
+// ids for different entities apart:
+//
+//	type User struct{}
+//	func (User) Prefix() string { return "u" }
+//
+//	var id puid.Typed[User] = puid.NewTyped[User]()
+type Prefixer interface {
+	Prefix() string
+}
+
+// An ID whose prefix is fixed by the type parameter.
+// Parsing, unmarshalling and scanning all reject ids with any other prefix.
+type Typed[P Prefixer] struct {
+	id ID
+}
+
+func prefixOf[P Prefixer]() string {
+	var p P
+	return p.Prefix()
+}
+
+// clones of the default generator per prefix, so NewTyped
+// doesn't have to make a new one for every id
+var typedGenerators sync.Map
+
+// Generate a new Typed id from the default generator
+func NewTyped[P Prefixer]() Typed[P] {
+	prefix := prefixOf[P]()
+	g, ok := typedGenerators.Load(prefix)
+	if !ok {
+		g, _ = typedGenerators.LoadOrStore(prefix, defaultGenerator.WithPrefix(prefix))
+	}
+	return Typed[P]{id: g.(*Generator).NewID()}
+}
+
+// Generate a new Typed id from the given generator, the prefix
+// from the type parameter replaces the generator's prefix.
+func NewTypedWith[P Prefixer](g *Generator) Typed[P] {
+	return Typed[P]{id: g.WithPrefix(prefixOf[P]()).NewID()}
+}
+
+// Parse and validate a puid which must have the prefix for P
+func ParseTyped[P Prefixer](s string) (Typed[P], error) {
+	id, err := parseValid(s)
+	if err == nil && id.Prefix != prefixOf[P]() {
+		err = &ParseError{Input: s, Err: ErrPrefix}
+	}
+	if err != nil {
+		return Typed[P]{}, err
+	}
+	return Typed[P]{id: id}, nil
+}
+
+// The untyped ID
+func (t Typed[P]) ID() ID {
+	return t.id
+}
+
+// Whether this is the empty id
+func (t Typed[P]) IsZero() bool {
+	return t.id.IsZero()
+}
+
+// The puid string, empty for the zero value
+func (t Typed[P]) String() string {
+	return t.id.String()
+}
+
+func (t *Typed[P]) set(s string) error {
+	v, err := ParseTyped[P](s)
+	if err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
+// implements encoding.TextMarshaler
+func (t Typed[P]) MarshalText() ([]byte, error) {
+	return t.id.MarshalText()
+}
+
+// implements encoding.TextUnmarshaler
+func (t *Typed[P]) UnmarshalText(b []byte) error {
+	return t.set(string(b))
+}
+
+// implements json.Marshaler, the zero value is `null`
+func (t Typed[P]) MarshalJSON() ([]byte, error) {
+	return t.id.MarshalJSON()
+}
+
+// implements json.Unmarshaler, `null` leaves the value untouched
+func (t *Typed[P]) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return t.set(s)
+}
+
+// implements driver.Valuer, the zero value is NULL
+func (t Typed[P]) Value() (driver.Value, error) {
+	return t.id.Value()
+}
+
+// implements sql.Scanner, NULL gives the zero value
+func (t *Typed[P]) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*t = Typed[P]{}
+		return nil
+	case string:
+		return t.set(v)
+	case []byte:
+		return t.set(string(v))
+	default:
+		return fmt.Errorf("puid: cannot scan %T into Typed", src)
+	}
+}
@@ -0,0 +1,72 @@
+package puid
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type testUser struct{}
+
+func (testUser) Prefix() string { return "u" }
+
+type testOrder struct{}
+
+func (testOrder) Prefix() string { return "o" }
+
+func Test_NewTyped(t *testing.T) {
+	u := NewTyped[testUser]()
+	if u.ID().Prefix != "u" || u.IsZero() {
+		t.Errorf("unexpected typed id %+v", u.ID())
+	}
+	o := NewTypedWith[testOrder](Cuid())
+	if o.ID().Prefix != "o" {
+		t.Errorf("prefix from the type should replace the generator's: %s", o)
+	}
+	if _, err := ParseTyped[testUser](u.String()); err != nil {
+		t.Errorf("unexpected error parsing typed id: %s", err)
+	}
+	if _, err := ParseTyped[testUser](o.String()); !errors.Is(err, ErrPrefix) {
+		t.Errorf("expected ErrPrefix parsing an order id as a user, got %v", err)
+	}
+}
+
+type testRecord struct {
+	User  Typed[testUser]  `json:"user"`
+	Order Typed[testOrder] `json:"order"`
+}
+
+func Test_TypedJSON(t *testing.T) {
+	in := testRecord{User: NewTyped[testUser]()}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"user":"`+in.User.String()+`","order":null}` {
+		t.Errorf("unexpected json %s", b)
+	}
+	var out testRecord
+	if err := json.Unmarshal(b, &out); err != nil || out != in {
+		t.Errorf("json did not round trip: %+v %v", out, err)
+	}
+	swapped := []byte(`{"order":"` + in.User.String() + `"}`)
+	if err := json.Unmarshal(swapped, &out); !errors.Is(err, ErrPrefix) {
+		t.Errorf("expected ErrPrefix unmarshalling a user id as an order, got %v", err)
+	}
+}
+
+func Test_TypedSQL(t *testing.T) {
+	u := NewTyped[testUser]()
+	v, _ := u.Value()
+	var out Typed[testUser]
+	if err := out.Scan(v); err != nil || out != u {
+		t.Errorf("scan did not round trip: %v %v", out, err)
+	}
+	if err := out.Scan(nil); err != nil || !out.IsZero() {
+		t.Errorf("NULL should scan to the zero value: %v %v", out, err)
+	}
+	var o Typed[testOrder]
+	if err := o.Scan([]byte(u.String())); !errors.Is(err, ErrPrefix) {
+		t.Errorf("expected ErrPrefix scanning a user id as an order, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package puid
+
+import (
+	"errors"
+	"time"
+)
+
+// The error a ParseError wraps when the timestamp is not plausible
+var ErrTimestamp = errors.New("puid: implausible timestamp")
+
+// The lowest timestamp that fills the 2 block time segment, "10000000" in
+// base36 is 36^7 milliseconds: Mon Jun 26 1972 00:49:24 GMT+0100 (BST)
+const minTimestamp = _4_DIGIT * _3_DIGIT
+
+// Check that s is a well-formed puid for this generator: the prefix, length
+// and characters are right and the timestamp is after 1972 and no further
+// in the future than the generator's skew allows.
+// The error is always a *ParseError.
+func (g *Generator) Validate(s string) error {
+	id, err := g.Parse(s)
+	if err != nil {
+		return err
+	}
+	return g.checkTimestamp(s, id.Timestamp)
+}
+
+// Check that s is a well-formed puid with any prefix, using the
+// default generator's skew
+func Validate(s string) error {
+	_, err := parseValid(s)
+	return err
+}
+
+// Whether s is a well-formed puid with any prefix
+func IsValid(s string) bool {
+	return Validate(s) == nil
+}
+
+// Parse with any prefix and validate the timestamp
+func parseValid(s string) (ID, error) {
+	id, err := Parse(s)
+	if err != nil {
+		return id, err
+	}
+	return id, defaultGenerator.checkTimestamp(s, id.Timestamp)
+}
+
+func (g *Generator) checkTimestamp(s string, t time.Time) error {
+	ms := t.UnixNano() / int64(time.Millisecond)
+	if ms < minTimestamp || t.After(g.clock.Now().Add(g.maxSkew)) {
+		return &ParseError{Input: s, Err: ErrTimestamp}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package puid
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_Validate(t *testing.T) {
+	g := WithPrefix("u")
+	if err := g.Validate(g.New()); err != nil {
+		t.Errorf("fresh id did not validate: %s", err)
+	}
+	if !IsValid(g.New()) || !IsValid(New()) || !IsValid(Cuid().New()) {
+		t.Error("fresh ids should be valid with any prefix")
+	}
+	tests := []struct {
+		s   string
+		err error
+	}{
+		{"o100000001111ffffrrrrrrrr", ErrPrefix},
+		{"u100000001111ffffrrrrrr", ErrLength},
+		{"u100000001111ffff-rrrrrrr", ErrCharacter},
+		// Mon Jun 26 1972 is the lowest we accept
+		{"u100000001111ffffrrrrrrrr", nil},
+		{"u0zzzzzzz1111ffffrrrrrrrr", ErrTimestamp},
+	}
+	for _, tt := range tests {
+		err := g.Validate(tt.s)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("validating `%s`: expected %v, got %v", tt.s, tt.err, err)
+		}
+		if IsValid(tt.s) != (tt.err == nil || tt.err == ErrPrefix) {
+			t.Errorf("unexpected IsValid(%q) result", tt.s)
+		}
+	}
+}
+
+func Test_ValidateSkew(t *testing.T) {
+	g := WithPrefix("")
+	future := func(d time.Duration) string {
+		ms := time.Now().Add(d).UnixNano() / int64(time.Millisecond)
+		return strconv.FormatInt(ms, BASE) + "1111ffffrrrrrrrr"
+	}
+	if err := g.Validate(future(30 * time.Second)); err != nil {
+		t.Errorf("id inside the default skew should validate: %s", err)
+	}
+	if err := g.Validate(future(time.Hour)); !errors.Is(err, ErrTimestamp) {
+		t.Errorf("id an hour ahead should not validate with the default skew: %v", err)
+	}
+	if err := g.WithMaxSkew(2 * time.Hour).Validate(future(time.Hour)); err != nil {
+		t.Errorf("id inside a custom skew should validate: %s", err)
+	}
+	if err := NewGenerator(&Options{Prefix: []byte{}, MaxSkew: time.Millisecond}).Validate(future(time.Minute)); !errors.Is(err, ErrTimestamp) {
+		t.Errorf("id outside a custom skew should not validate: %v", err)
+	}
+}